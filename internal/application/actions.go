@@ -2,14 +2,13 @@ package application
 
 import (
 	"dinky/internal/application/filtercommandaction"
+	"dinky/internal/application/selections"
 	"dinky/internal/application/settingstype"
 	"dinky/internal/tui/dialog"
 	"dinky/internal/tui/filedialog"
 	"dinky/internal/tui/settingsdialog"
 	"dinky/internal/tui/style"
 	"dinky/internal/utility"
-	"encoding/json"
-	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
@@ -17,7 +16,6 @@ import (
 	"strings"
 	"syscall"
 
-	"github.com/google/renameio/v2"
 	"github.com/rivo/tview"
 	"github.com/sedwards2009/smidgen"
 	"github.com/sedwards2009/smidgen/micro/buffer"
@@ -29,11 +27,17 @@ const (
 	ACTION_OPEN_FILE                  = "OpenFile"
 	ACTION_SAVE_FILE                  = "SaveFile"
 	ACTION_SAVE_FILE_AS               = "SaveFileAs"
+	ACTION_SAVE_ALL                   = "SaveAll"
+	ACTION_CLOSE_ALL                  = "CloseAll"
+	ACTION_CLOSE_OTHERS               = "CloseOthers"
+	ACTION_CLOSE_TO_THE_RIGHT         = "CloseToTheRight"
 	ACTION_OPEN_FILE_MENU             = "OpenFileMenu"
 	ACTION_OPEN_EDIT_MENU             = "OpenEditMenu"
 	ACTION_OPEN_SELECTION_MENU        = "OpenSelectionMenu"
 	ACTION_OPEN_GO_MENU               = "OpenGoMenu"
 	ACTION_OPEN_TRANSFORM_MENU        = "OpenTransformMenu"
+	ACTION_OPEN_ENCODE_MENU           = "OpenEncodeMenu"
+	ACTION_OPEN_DATA_MENU             = "OpenDataMenu"
 	ACTION_OPEN_VIEW_MENU             = "OpenViewMenu"
 	ACTION_OPEN_HELP_MENU             = "OpenHelpMenu"
 	ACTION_TOGGLE_SOFT_WRAP           = "ToggleSoftWrap"
@@ -41,6 +45,7 @@ const (
 	ACTION_SET_TAB_SIZE               = "SetTabSize"
 	ACTION_SET_TAB_CHARACTER          = "SetTabCharacter"
 	ACTION_SET_LINE_ENDINGS           = "SetLineEndings"
+	ACTION_SET_ENCODING               = "SetEncoding"
 	ACTION_SET_SYNTAX_HIGHLIGHTING    = "SetSyntaxHighlighting"
 	ACTION_SET_VERTICAL_RULER         = "SetVerticalRuler"
 	ACTION_GO_TO_LINE                 = "GoToLine"
@@ -55,19 +60,51 @@ const (
 	ACTION_TOGGLE_WHITESPACE          = "ToggleWhitespace"
 	ACTION_TOGGLE_TRAILING_WHITESPACE = "ToggleTrailingWhitespace"
 	ACTION_FIND_AND_REPLACE           = "FindAndReplace"
+	ACTION_FIND_IN_OPEN_FILES         = "FindInOpenFiles"
+	ACTION_FIND_IN_FILES              = "FindInFiles"
+	ACTION_REPLACE_IN_FILES           = "ReplaceInFiles"
 	ACTION_SETTINGS                   = "Settings"
 	ACTION_SUSPEND                    = "Suspend"
 	ACTION_TO_UPPERCASE               = "ToUppercase"
 	ACTION_TO_LOWERCASE               = "ToLowercase"
+	ACTION_TO_TITLE_CASE              = "ToTitleCase"
+	ACTION_TO_CAMEL_CASE              = "ToCamelCase"
+	ACTION_TO_SNAKE_CASE              = "ToSnakeCase"
+	ACTION_TO_KEBAB_CASE              = "ToKebabCase"
+	ACTION_TO_CONSTANT_CASE           = "ToConstantCase"
+	ACTION_ROT13                      = "Rot13"
+	ACTION_UNICODE_NFC                = "UnicodeNFC"
+	ACTION_UNICODE_NFD                = "UnicodeNFD"
+	ACTION_BASE64_ENCODE              = "Base64Encode"
+	ACTION_BASE64_DECODE              = "Base64Decode"
+	ACTION_HEX_ENCODE                 = "HexEncode"
+	ACTION_HEX_DECODE                 = "HexDecode"
+	ACTION_HTML_ESCAPE                = "HTMLEscape"
+	ACTION_HTML_UNESCAPE              = "HTMLUnescape"
+	ACTION_JSON_STRING_ESCAPE         = "JSONStringEscape"
+	ACTION_JSON_STRING_UNESCAPE       = "JSONStringUnescape"
 	ACTION_URL_ENCODE                 = "UrlEncode"
 	ACTION_URL_DECODE                 = "UrlDecode"
 	ACTION_SORT_LINES                 = "SortLines"
 	ACTION_REVERSE_LINES              = "ReverseLines"
 	ACTION_FORMAT_JSON                = "FormatJSON"
+	ACTION_FORMAT_JSON_SORTED_KEYS    = "FormatJSONSortedKeys"
+	ACTION_MINIFY_JSON                = "MinifyJSON"
+	ACTION_FORMAT_XML                 = "FormatXML"
+	ACTION_FORMAT_YAML                = "FormatYAML"
+	ACTION_FORMAT_TOML                = "FormatTOML"
+	ACTION_JSON_TO_YAML               = "ConvertJSONToYAML"
+	ACTION_YAML_TO_JSON               = "ConvertYAMLToJSON"
+	ACTION_CSV_TO_MARKDOWN_TABLE      = "ConvertCSVToMarkdownTable"
+	ACTION_MARKDOWN_TABLE_TO_CSV      = "ConvertMarkdownTableToCSV"
+	ACTION_JSON_TO_CSV                = "ConvertJSONToCSV"
 	ACTION_HARD_WORD_WRAP             = "HardWordWrap"
 	ACTION_NEXT_BOOKMARK              = "NextBookmark"
 	ACTION_PREVIOUS_BOOKMARK          = "PreviousBookmark"
 	ACTION_FILTER_EXTERNAL_COMMAND    = "FilterExternalCommands"
+	ACTION_COMMAND_PALETTE            = "CommandPalette"
+	ACTION_KEY_BINDINGS               = "KeyBindings"
+	ACTION_SHORTCUT_REFERENCE         = "ShortcutReference"
 )
 
 var dinkyActionMapping map[string]func() tview.Primitive
@@ -82,15 +119,22 @@ func init() {
 		ACTION_OPEN_SELECTION_MENU:        handleOpenSelectionMenu,
 		ACTION_OPEN_GO_MENU:               handleOpenGoMenu,
 		ACTION_OPEN_TRANSFORM_MENU:        handleOpenTransformMenu,
+		ACTION_OPEN_ENCODE_MENU:           handleOpenEncodeMenu,
+		ACTION_OPEN_DATA_MENU:             handleOpenDataMenu,
 		ACTION_OPEN_VIEW_MENU:             handleOpenViewMenu,
 		ACTION_OPEN_HELP_MENU:             handleOpenHelpMenu,
 		ACTION_SAVE_FILE:                  handleSaveFile,
 		ACTION_SAVE_FILE_AS:               handleSaveFileAs,
+		ACTION_SAVE_ALL:                   handleSaveAll,
+		ACTION_CLOSE_ALL:                  handleCloseAll,
+		ACTION_CLOSE_OTHERS:               handleCloseOthers,
+		ACTION_CLOSE_TO_THE_RIGHT:         handleCloseToTheRight,
 		ACTION_TOGGLE_SOFT_WRAP:           handleSoftWrap,
 		ACTION_TOGGLE_MATCH_BRACKET:       handleMatchBracket,
 		ACTION_SET_TAB_SIZE:               handleSetTabSize,
 		ACTION_SET_TAB_CHARACTER:          handleSetTabCharacter,
 		ACTION_SET_LINE_ENDINGS:           handleSetLineEndings,
+		ACTION_SET_ENCODING:               handleSetEncoding,
 		ACTION_SET_SYNTAX_HIGHLIGHTING:    handleSetSyntaxHighlighting,
 		ACTION_SET_VERTICAL_RULER:         handleSetVerticalRuler,
 		ACTION_GO_TO_LINE:                 handleGoToLine,
@@ -105,20 +149,33 @@ func init() {
 		ACTION_TOGGLE_WHITESPACE:          handleToggleWhitespace,
 		ACTION_TOGGLE_TRAILING_WHITESPACE: handleToggleTrailingWhitespace,
 		ACTION_FIND_AND_REPLACE:           handleFindAndReplace,
+		ACTION_FIND_IN_OPEN_FILES:         handleFindInOpenFiles,
+		ACTION_FIND_IN_FILES:              handleFindInFiles,
+		ACTION_REPLACE_IN_FILES:           handleReplaceInFiles,
 		ACTION_SETTINGS:                   handleSettings,
 		ACTION_SUSPEND:                    handleSuspend,
-		ACTION_TO_UPPERCASE:               handleToUppercase,
-		ACTION_TO_LOWERCASE:               handleToLowercase,
-		ACTION_URL_ENCODE:                 handleURLEncode,
-		ACTION_URL_DECODE:                 handleURLDecode,
 		ACTION_SORT_LINES:                 handleSortLines,
 		ACTION_REVERSE_LINES:              handleReverseLines,
 		ACTION_FORMAT_JSON:                handleFormatJSON,
+		ACTION_FORMAT_JSON_SORTED_KEYS:    handleFormatJSONSortedKeys,
+		ACTION_MINIFY_JSON:                handleMinifyJSON,
+		ACTION_FORMAT_XML:                 handleFormatXML,
+		ACTION_FORMAT_YAML:                handleFormatYAML,
+		ACTION_FORMAT_TOML:                handleFormatTOML,
+		ACTION_JSON_TO_YAML:               handleConvertJSONToYAML,
+		ACTION_YAML_TO_JSON:               handleConvertYAMLToJSON,
+		ACTION_CSV_TO_MARKDOWN_TABLE:      handleConvertCSVToMarkdownTable,
+		ACTION_MARKDOWN_TABLE_TO_CSV:      handleConvertMarkdownTableToCSV,
+		ACTION_JSON_TO_CSV:                handleConvertJSONToCSV,
 		ACTION_HARD_WORD_WRAP:             handleHardWordWrap,
 		ACTION_NEXT_BOOKMARK:              handleNextBookmark,
 		ACTION_PREVIOUS_BOOKMARK:          handlePreviousBookmark,
 		ACTION_FILTER_EXTERNAL_COMMAND:    handleFilterExternalCommand,
+		ACTION_COMMAND_PALETTE:            handleCommandPalette,
+		ACTION_KEY_BINDINGS:               handleKeyBindings,
+		ACTION_SHORTCUT_REFERENCE:         handleShortcutReference,
 	}
+	registerTransforms()
 }
 
 func handleDinkyAction(id string) tview.Primitive {
@@ -143,8 +200,8 @@ func showFileDialog(title string, mode filedialog.FileDialogMode, defaultPath st
 	if fileDialog == nil {
 		fileDialog = filedialog.NewFileDialog(app)
 		style.StyleFileDialog(fileDialog)
-		fileDialog.SetSmidgenKeybindings(smidgenDefaultKeyBindings)
 	}
+	fileDialog.SetSmidgenKeybindings(smidgenDefaultKeyBindings)
 	fileDialog.SetTitle(title)
 	if defaultPath == "" {
 		cwd, err := os.Getwd()
@@ -203,66 +260,102 @@ func bufferToBytes(buffer *buffer.Buffer) []byte {
 	return buffer.LineArray.Bytes()
 }
 
-func writeFile(filename string, buffer *buffer.Buffer) (ok bool, message string) {
-	contents := bufferToBytes(buffer)
-	err := renameio.WriteFile(filename, contents, 0644)
-	if err != nil {
-		return false, "Error writing file: " + err.Error()
-	}
-	return true, "Wrote file " + filename
+func handleSaveFile() tview.Primitive {
+	return saveFileBuffer(getFileBufferByID(fileBufferID), nil)
 }
 
-func handleSaveFile() tview.Primitive {
-	fileBuffer := getFileBufferByID(fileBufferID)
+// saveFileBuffer saves a buffer, asking for a filename if it is Untitled and
+// for confirmation if the file has changed on disk. onSaved is called once
+// the buffer has been written successfully, and may be nil.
+func saveFileBuffer(fileBuffer *FileBuffer, onSaved func()) tview.Primitive {
 	if fileBuffer.filename == "" {
-		return handleSaveFileAs()
-	} else {
-		writeCurrentFileBuffer()
+		return saveFileBufferAs(fileBuffer, onSaved)
 	}
-	return nil
+	if hasChangedOnDisk(fileBuffer) {
+		return showSaveChangedFileDialog(fileBuffer, onSaved)
+	}
+	return writeFileBuffer(fileBuffer, onSaved)
 }
 
-func writeCurrentFileBuffer() {
-	fileBuffer := getFileBufferByID(fileBufferID)
-	ok, message := writeFile(fileBuffer.filename, fileBuffer.buffer)
+// writeFileBuffer writes a buffer to its file. The buffer is only marked as
+// unmodified once the write has been verified. On failure a dialog offering
+// to retry or save elsewhere is returned.
+func writeFileBuffer(fileBuffer *FileBuffer, onSaved func()) tview.Primitive {
+	warning, err := saveBufferToFile(fileBuffer.filename, fileBuffer.buffer, fileBuffer.encoding)
+	if err != nil {
+		statusBar.ShowError("Error writing file: " + err.Error())
+		return showSaveFailedDialog(fileBuffer, err, onSaved)
+	}
+
 	fileBuffer.buffer.ClearModified()
-	if ok {
-		statusBar.ShowMessage(message)
+	recordDiskFileState(fileBuffer)
+	removeRecoveryFile(fileBuffer)
+	if warning != "" {
+		statusBar.ShowWarning("Wrote file " + fileBuffer.filename + ". " + warning)
 	} else {
-		statusBar.ShowError(message)
+		statusBar.ShowMessage("Wrote file " + fileBuffer.filename)
 	}
+	if onSaved != nil {
+		onSaved()
+	}
+	return nil
+}
+
+func showSaveFailedDialog(fileBuffer *FileBuffer, err error, onSaved func()) tview.Primitive {
+	selectTab(fileBuffer.uuid)
+	message := "Couldn't save '" + fileBuffer.filename + "':\n" + err.Error()
+	return ShowMessageDialog("Save Failed", message, []string{"Retry", "Save As…", "Cancel"},
+		func() {
+			CloseMessageDialog()
+		},
+		func(button string, index int) {
+			CloseMessageDialog()
+			var nextFocus tview.Primitive
+			switch index {
+			case 0:
+				nextFocus = writeFileBuffer(fileBuffer, onSaved)
+			case 1:
+				nextFocus = saveFileBufferAs(fileBuffer, onSaved)
+			}
+			if nextFocus != nil {
+				app.SetFocus(nextFocus)
+			}
+		})
 }
 
 func handleSaveFileAs() tview.Primitive {
-	fileBuffer := getFileBufferByID(fileBufferID)
+	return saveFileBufferAs(getFileBufferByID(fileBufferID), nil)
+}
+
+func saveFileBufferAs(fileBuffer *FileBuffer, onSaved func()) tview.Primitive {
+	selectTab(fileBuffer.uuid)
 	return showFileDialog("Save File As", filedialog.SAVE_FILE_MODE, fileBuffer.filename,
 		func(accepted bool, filePath string) {
 			hideFileDialog()
 			if !accepted {
 				return
 			}
+			// The buffer is a normal file from now on.
+			stopSearch(fileBuffer)
+			fileBuffer.onEnter = nil
+			unwatchFileBuffer(fileBuffer)
 			fileBuffer.filename = filePath
-			tabBarLine.SetTabTitle(fileBufferID, filepath.Base(fileBuffer.filename))
-			writeCurrentFileBuffer()
+			watchFileBuffer(fileBuffer)
+			tabBarLine.SetTabTitle(fileBuffer.uuid, filepath.Base(fileBuffer.filename))
+			if nextFocus := writeFileBuffer(fileBuffer, onSaved); nextFocus != nil {
+				app.SetFocus(nextFocus)
+			}
 		})
 }
 
 func handleCloseFile() tview.Primitive {
-	fileBuffer := getFileBufferByID(fileBufferID)
-	if fileBuffer.buffer.Modified() {
-		return ShowConfirmDialog("File has unsaved changes. Close anyway?", func() {
-			closeFile(fileBufferID)
-			if len(fileBuffers) == 0 {
-				handleQuit()
-			}
-		}, func() {})
-	} else {
-		closeFile(fileBufferID)
+	return closeFileBuffers([]*FileBuffer{getFileBufferByID(fileBufferID)}, func() {
 		if len(fileBuffers) == 0 {
-			handleQuit()
+			if nextFocus := handleQuit(); nextFocus != nil {
+				app.SetFocus(nextFocus)
+			}
 		}
-	}
-	return nil
+	})
 }
 
 func closeFile(fileBufferID string) {
@@ -271,6 +364,11 @@ func closeFile(fileBufferID string) {
 
 	for i, fileBuffer := range fileBuffers {
 		if fileBuffer.uuid == fileBufferID {
+			stopSearch(fileBuffer)
+			unwatchFileBuffer(fileBuffer)
+			removeRecoveryFile(fileBuffer)
+			capturePipeOutput(fileBuffer)
+			notifyFileBufferClosed(fileBufferID)
 			fileBuffers = append(fileBuffers[:i], fileBuffers[i+1:]...)
 			break
 		}
@@ -313,18 +411,30 @@ func handleOpenTransformMenu() tview.Primitive {
 	return nil
 }
 
-func handleOpenViewMenu() tview.Primitive {
+func handleOpenEncodeMenu() tview.Primitive {
 	menuBar.Open(5)
 	app.SetFocus(menuBar)
 	return nil
 }
 
-func handleOpenHelpMenu() tview.Primitive {
+func handleOpenDataMenu() tview.Primitive {
 	menuBar.Open(6)
 	app.SetFocus(menuBar)
 	return nil
 }
 
+func handleOpenViewMenu() tview.Primitive {
+	menuBar.Open(7)
+	app.SetFocus(menuBar)
+	return nil
+}
+
+func handleOpenHelpMenu() tview.Primitive {
+	menuBar.Open(8)
+	app.SetFocus(menuBar)
+	return nil
+}
+
 func handleSoftWrap() tview.Primitive {
 	buffer := currentFileBuffer.buffer
 	on := buffer.Settings["softwrap"].(bool)
@@ -540,32 +650,12 @@ func handleSetVerticalRuler() tview.Primitive {
 }
 
 func handleQuit() tview.Primitive {
-	var closeNextFileBuffer func() tview.Primitive
-	closeNextFileBuffer = func() tview.Primitive {
-		if len(fileBuffers) > 0 {
-			fileBuffer := fileBuffers[0]
-			if fileBuffer.buffer.Modified() {
-				selectTab(fileBuffer.uuid)
-				return ShowConfirmDialog("File has unsaved changes. Close anyway?", func() {
-					closeFile(fileBuffer.uuid)
-					nextFocus := closeNextFileBuffer()
-					if nextFocus != nil {
-						app.SetFocus(nextFocus)
-					}
-				}, func() {})
-			} else {
-				closeFile(fileBuffer.uuid)
-				nextFocus := closeNextFileBuffer()
-				if nextFocus != nil {
-					app.SetFocus(nextFocus)
-				}
-			}
-		} else {
-			app.Stop()
-		}
+	modifiedFileBuffers := unsavedFileBuffers()
+	if len(modifiedFileBuffers) == 0 {
+		quitNow()
 		return nil
 	}
-	return closeNextFileBuffer()
+	return showQuitDialog(modifiedFileBuffers)
 }
 
 func handleFind() tview.Primitive {
@@ -669,9 +759,21 @@ func handleSettings() tview.Primitive {
 			modalPages.RemovePage(settingsDialogName)
 		})
 		settingsDialog.SetOkFunc(func(newSettings settingstype.Settings) {
+			keymapChanged := newSettings.Keymap != settings.Keymap
+			historySizeChanged := newSettings.HistorySize != settings.HistorySize
 			settings = newSettings
 			SaveSettings(settings)
 			loadEditorColorScheme(settings.ColorScheme)
+			if historySizeChanged {
+				applyHistorySize()
+			}
+			if keymapChanged {
+				if problems := reloadKeyBindings(); len(problems) != 0 {
+					statusBar.ShowWarning("Key bindings: " + problems[0])
+				} else {
+					statusBar.ShowMessage("Keymap set to " + keymapTitle(settings.Keymap))
+				}
+			}
 		})
 	}
 	settingsDialog.SetSettings(settings)
@@ -687,132 +789,21 @@ func handleSuspend() tview.Primitive {
 	return nil
 }
 
-func transformSelection(transformFunc func(string) string) {
-	if !currentFileBuffer.editor.Cursor().HasSelection() {
-		statusBar.ShowWarning("No text selected")
-		return
-	}
-	currentFileBuffer.editor.ActionController().TransformSelection(func(lines []string) []string {
-		result := make([]string, len(lines))
-		for i, line := range lines {
-			result[i] = transformFunc(line)
-		}
-		return result
-	})
-}
-
-func handleToUppercase() tview.Primitive {
-	transformSelection(func(s string) string {
-		return strings.ToUpper(s)
-	})
-	return nil
-}
-
-func handleToLowercase() tview.Primitive {
-	transformSelection(func(s string) string {
-		return strings.ToLower(s)
-	})
-	return nil
-}
-
-func handleURLEncode() tview.Primitive {
-	transformSelection(func(s string) string {
-		return url.QueryEscape(s)
-	})
-	return nil
-}
-
-func handleURLDecode() tview.Primitive {
-	transformSelection(func(s string) string {
-		decoded, err := url.QueryUnescape(s)
-		if err != nil {
-			statusBar.ShowWarning("Invalid URL encoding: " + err.Error())
-			return s
-		}
-		return decoded
-	})
-	return nil
-}
-
-func handleSortLines() tview.Primitive {
-	if !currentFileBuffer.editor.Cursor().HasSelection() {
-		statusBar.ShowWarning("No text selected")
-		return nil
-	}
-	currentFileBuffer.editor.ActionController().TransformSelection(func(lines []string) []string {
-		lastLine := lines[len(lines)-1]
-		if lastLine == "" { // Leave the last line out of the sort if it is zero length.
-			result := append([]string(nil), lines[:len(lines)-1]...)
-			slices.Sort(result)
-			result = append(result, lastLine)
-			return result
-		} else {
-			result := append([]string(nil), lines...)
-			slices.Sort(result)
-			return result
-		}
-	})
-	return nil
-}
-
 func handleReverseLines() tview.Primitive {
-	if !currentFileBuffer.editor.Cursor().HasSelection() {
+	if !selections.HasSelection(currentFileBuffer.editor) {
 		statusBar.ShowWarning("No text selected")
 		return nil
 	}
-	currentFileBuffer.editor.ActionController().TransformSelection(func(lines []string) []string {
-		lastLine := lines[len(lines)-1]
-		if lastLine == "" { // Leave the last line out of the sort if it is zero length.
-			result := append([]string(nil), lines[:len(lines)-1]...)
-			slices.Reverse(result)
-			result = append(result, lastLine)
-			return result
-		} else {
-			result := append([]string(nil), lines...)
-			slices.Reverse(result)
-			return result
-		}
-	})
-	return nil
-}
-
-func handleFormatJSON() tview.Primitive {
-	if !currentFileBuffer.editor.Cursor().HasSelection() {
-		statusBar.ShowWarning("No text selected")
-		return nil
-	}
-
-	currentFileBuffer.editor.ActionController().TransformSelection(func(lines []string) []string {
-		jsonStr := strings.Join(lines, "\n")
-		var jsonObj interface{}
-		err := json.Unmarshal([]byte(jsonStr), &jsonObj)
-		if err != nil {
-			statusBar.ShowWarning("Invalid JSON: " + err.Error())
-			return lines
-		}
-
-		var indentChar string
-		if currentFileBuffer.buffer.Settings["tabstospaces"].(bool) {
-			// Spaces for indentation
-			indentChar = strings.Repeat(" ", int(currentFileBuffer.buffer.Settings["tabsize"].(float64)))
-		} else {
-			indentChar = "\t"
-		}
-
-		// Marshal with indentation
-		formattedJSON, err := json.MarshalIndent(jsonObj, "", indentChar)
-		if err != nil {
-			statusBar.ShowWarning("Error: " + err.Error())
-			return lines
-		}
-		// Split back into lines
-		return strings.Split(string(formattedJSON), "\n")
+	selections.ReplaceLines(currentFileBuffer.editor, func(lines []string) ([]string, error) {
+		result := slices.Clone(lines)
+		slices.Reverse(result)
+		return result, nil
 	})
 	return nil
 }
 
 func handleHardWordWrap() tview.Primitive {
-	if !currentFileBuffer.editor.Cursor().HasSelection() {
+	if !selections.HasSelection(currentFileBuffer.editor) {
 		statusBar.ShowWarning("No text selected")
 		return nil
 	}
@@ -826,11 +817,7 @@ func handleHardWordWrap() tview.Primitive {
 	}
 
 	wrapWidth = verticalRuler
-	currentFileBuffer.editor.ActionController().TransformSelection(func(lines []string) []string {
-		if len(lines) == 0 {
-			return lines
-		}
-
+	selections.ReplaceLines(currentFileBuffer.editor, func(lines []string) ([]string, error) {
 		var result []string
 		var paragraphLines []string
 
@@ -857,7 +844,7 @@ func handleHardWordWrap() tview.Primitive {
 			result = append(result, wrappedParagraph...)
 		}
 
-		return result
+		return result, nil
 	})
 	return nil
 }
@@ -0,0 +1,199 @@
+package application
+
+import (
+	"dinky/internal/tui/dialog"
+	"slices"
+
+	"github.com/rivo/tview"
+)
+
+// isQuitting is true while quitNow is closing the open buffers.
+var isQuitting bool
+
+// needsSaving returns true if closing the buffer would lose changes. Text
+// read from stdin only exists in its buffer until it is saved somewhere.
+func needsSaving(fileBuffer *FileBuffer) bool {
+	if isPipeFileBuffer(fileBuffer) {
+		return false
+	}
+	if fileBuffer == stdinFileBuffer && fileBuffer.filename == "" && fileBuffer.buffer.Size() != 0 {
+		return true
+	}
+	return fileBuffer.buffer.Modified()
+}
+
+func unsavedFileBuffers() []*FileBuffer {
+	result := []*FileBuffer{}
+	for _, fileBuffer := range fileBuffers {
+		if needsSaving(fileBuffer) {
+			result = append(result, fileBuffer)
+		}
+	}
+	return result
+}
+
+// showSaveChangesDialog asks what to do with a modified buffer which is about
+// to be closed. onClose is called once it is OK to close the buffer.
+func showSaveChangesDialog(fileBuffer *FileBuffer, onClose func()) tview.Primitive {
+	selectTab(fileBuffer.uuid)
+	message := "'" + tabBarLine.TabTitle(fileBuffer.uuid) + "' has unsaved changes.\nSave them before closing?"
+	return ShowMessageDialog("Unsaved Changes", message, []string{"Save", "Don't Save", "Cancel"},
+		func() {
+			CloseMessageDialog()
+		},
+		func(button string, index int) {
+			CloseMessageDialog()
+			switch index {
+			case 0:
+				if nextFocus := saveFileBuffer(fileBuffer, onClose); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			case 1:
+				onClose()
+			}
+		})
+}
+
+// closeFileBuffers closes buffers one after the other, asking about unsaved
+// changes on the way. Cancelling stops the whole lot. onDone is called after
+// the last one has been closed.
+func closeFileBuffers(toClose []*FileBuffer, onDone func()) tview.Primitive {
+	for len(toClose) > 0 {
+		fileBuffer := toClose[0]
+		toClose = toClose[1:]
+		if getFileBufferByID(fileBuffer.uuid) == nil {
+			continue
+		}
+
+		if needsSaving(fileBuffer) {
+			remaining := toClose
+			return showSaveChangesDialog(fileBuffer, func() {
+				closeFile(fileBuffer.uuid)
+				if nextFocus := closeFileBuffers(remaining, onDone); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			})
+		}
+		closeFile(fileBuffer.uuid)
+	}
+
+	if onDone != nil {
+		onDone()
+	}
+	return nil
+}
+
+func handleCloseAll() tview.Primitive {
+	return closeFileBuffers(slices.Clone(fileBuffers), func() {
+		if len(fileBuffers) == 0 {
+			newFile("", "")
+		}
+	})
+}
+
+func handleCloseOthers() tview.Primitive {
+	others := []*FileBuffer{}
+	for _, fileBuffer := range fileBuffers {
+		if fileBuffer.uuid != fileBufferID {
+			others = append(others, fileBuffer)
+		}
+	}
+	return closeFileBuffers(others, nil)
+}
+
+func handleCloseToTheRight() tview.Primitive {
+	currentID := fileBufferID
+	for i, fileBuffer := range fileBuffers {
+		if fileBuffer.uuid == currentID {
+			return closeFileBuffers(slices.Clone(fileBuffers[i+1:]), func() {
+				if getFileBufferByID(currentID) != nil {
+					selectTab(currentID)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// saveFileBuffers saves buffers one after the other. onDone is called once
+// all of them have been saved.
+func saveFileBuffers(toSave []*FileBuffer, onDone func()) tview.Primitive {
+	if len(toSave) == 0 {
+		if onDone != nil {
+			onDone()
+		}
+		return nil
+	}
+	return saveFileBuffer(toSave[0], func() {
+		if nextFocus := saveFileBuffers(toSave[1:], onDone); nextFocus != nil {
+			app.SetFocus(nextFocus)
+		}
+	})
+}
+
+func handleSaveAll() tview.Primitive {
+	toSave := unsavedFileBuffers()
+	if len(toSave) == 0 {
+		statusBar.ShowMessage("No unsaved changes")
+		return nil
+	}
+	return saveFileBuffers(toSave, func() {
+		statusBar.ShowMessage("Saved all files")
+	})
+}
+
+// showQuitDialog lists the modified buffers so that the user can pick which
+// ones to save before quitting.
+func showQuitDialog(modifiedFileBuffers []*FileBuffer) tview.Primitive {
+	items := []dialog.ListItem{}
+	for _, fileBuffer := range modifiedFileBuffers {
+		text := tabBarLine.TabTitle(fileBuffer.uuid)
+		if fileBuffer.filename != "" {
+			text = fileBuffer.filename
+		}
+		items = append(items, dialog.ListItem{Text: text, Value: fileBuffer.uuid, Checked: true})
+	}
+
+	return ShowListDialog(dialog.ListDialogOptions{
+		Title:      "Unsaved Changes",
+		Message:    "Save the checked files before quitting? (Space toggles)",
+		Buttons:    []string{"Save & Quit", "Quit Without Saving", "Cancel"},
+		Width:      70,
+		Height:     min(len(items), 10) + 8,
+		Items:      items,
+		Checkboxes: true,
+		OnCancel: func() {
+			hideListDialog()
+		},
+		OnAccept: func(value string, buttonIndex int) {
+			checkedIDs := listDialog.CheckedValues()
+			hideListDialog()
+			switch buttonIndex {
+			case -1, 0:
+				toSave := []*FileBuffer{}
+				for _, id := range checkedIDs {
+					if fileBuffer := getFileBufferByID(id); fileBuffer != nil {
+						toSave = append(toSave, fileBuffer)
+					}
+				}
+				if nextFocus := saveFileBuffers(toSave, quitNow); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			case 1:
+				quitNow()
+			}
+		},
+	})
+}
+
+// quitNow closes everything without asking and stops the application.
+func quitNow() {
+	isQuitting = true
+	if !pipeMode {
+		saveSession()
+	}
+	for len(fileBuffers) > 0 {
+		closeFile(fileBuffers[0].uuid)
+	}
+	app.Stop()
+}
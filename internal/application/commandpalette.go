@@ -0,0 +1,97 @@
+package application
+
+import (
+	"dinky/internal/tui/commandpalette"
+	"dinky/internal/tui/menu"
+	"dinky/internal/tui/smidgeninputfield"
+	"dinky/internal/tui/style"
+	"maps"
+	"slices"
+
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen/micro/action"
+)
+
+const commandPaletteName = "commandPalette"
+
+var commandPalette *commandpalette.CommandPalette
+
+// IDs of the commands run from the palette, most recent first
+var recentCommands []string
+
+func handleCommandPalette() tview.Primitive {
+	if commandPalette == nil {
+		commandPalette = commandpalette.NewCommandPalette(app)
+		style.StyleCommandPalette(commandPalette)
+	}
+	commandPalette.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+
+	commands, menuItems := paletteCommands()
+
+	modalPages.AddPage(commandPaletteName, commandPalette, true, true)
+	commandPalette.Open(commandpalette.CommandPaletteOptions{
+		Commands: commands,
+		Recent:   recentCommands,
+		OnCancel: func() {
+			closeCommandPalette()
+			app.SetFocus(currentFileBuffer.editor)
+		},
+		OnAccept: func(command commandpalette.Command) {
+			closeCommandPalette()
+			app.SetFocus(currentFileBuffer.editor)
+
+			recentCommands = smidgeninputfield.AddToHistory(recentCommands, command.ID, settings.HistorySize)
+			saveHistory()
+
+			if item, ok := menuItems[command.ID]; ok {
+				if p := item.Callback(item.ID); p != nil {
+					app.SetFocus(p)
+				}
+				return
+			}
+			runAction(command.ID)
+		},
+	})
+	return commandPalette
+}
+
+// paletteCommands lists the menu commands followed by every other Dinky and
+// smidgen action. It also returns the menu items, which are run through the
+// menu so that they behave the same way.
+func paletteCommands() ([]commandpalette.Command, map[string]*menu.MenuItem) {
+	commands := []commandpalette.Command{}
+	menuItems := map[string]*menu.MenuItem{}
+	for _, command := range listMenuCommands() {
+		menuItems[command.ID] = command.Item
+		commands = append(commands, commandpalette.Command{
+			ID:    command.ID,
+			Title: command.Title,
+			Key:   actionToKeyMapping[command.ID],
+		})
+	}
+
+	others := slices.Concat(slices.Collect(maps.Keys(dinkyActionMapping)),
+		slices.Collect(maps.Keys(action.BufKeyActions)))
+	slices.Sort(others)
+	for _, actionName := range slices.Compact(others) {
+		if _, ok := menuItems[actionName]; ok {
+			continue
+		}
+		commands = append(commands, commandpalette.Command{
+			ID:    actionName,
+			Title: "Other: " + actionTitle(actionName),
+			Key:   actionToKeyMapping[actionName],
+		})
+	}
+
+	return slices.DeleteFunc(commands, func(command commandpalette.Command) bool {
+		return command.ID == ACTION_COMMAND_PALETTE
+	}), menuItems
+}
+
+func closeCommandPalette() {
+	if commandPalette != nil {
+		commandPalette.Close()
+		modalPages.RemovePage(commandPaletteName)
+	}
+}
@@ -0,0 +1,479 @@
+package application
+
+import (
+	"bytes"
+	"dinky/internal/application/selections"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+)
+
+func handleConvertJSONToYAML() tview.Primitive {
+	convertData("JSON", "yaml", jsonToYAML)
+	return nil
+}
+
+func handleConvertYAMLToJSON() tview.Primitive {
+	convertData("YAML", "json", yamlToJSON)
+	return nil
+}
+
+func handleConvertCSVToMarkdownTable() tview.Primitive {
+	convertData("CSV", "markdown", csvToMarkdownTable)
+	return nil
+}
+
+func handleConvertMarkdownTableToCSV() tview.Primitive {
+	convertData("Markdown table", "", markdownTableToCSV)
+	return nil
+}
+
+func handleConvertJSONToCSV() tview.Primitive {
+	convertData("JSON", "", jsonToCSV)
+	return nil
+}
+
+// convertData converts the selected text, or the whole file if nothing is
+// selected, and opens the result in a new tab with the given filetype. If
+// the text can't be parsed, the cursor is put where the problem is. Only the
+// current cursor's selection is converted when there are multiple cursors,
+// as the result is one new file.
+func convertData(formatName string, filetype string, convert func(text string, indent string) (string, error)) {
+	otherSelections := selections.Count(currentFileBuffer.editor) > 1
+	text, start := dataText()
+	if strings.TrimSpace(text) == "" {
+		statusBar.ShowWarning("There is no " + formatName + " to convert")
+		return
+	}
+	converted, err := convert(text, indentUnit())
+	if err != nil {
+		showDataError(formatName, text, start, err)
+		return
+	}
+
+	fileBuffer := newFile(strings.TrimRight(converted, "\n")+"\n", "")
+	if filetype != "" {
+		fileBuffer.buffer.Settings["filetype"] = filetype
+		fileBuffer.buffer.UpdateRules()
+	}
+	syncMenuFromBuffer(fileBuffer.buffer)
+	if otherSelections {
+		statusBar.ShowWarning("Only the current cursor's selection was converted")
+	}
+}
+
+func jsonToYAML(text string, indent string) (string, error) {
+	// YAML would take more than JSON allows.
+	if err := json.Compact(&bytes.Buffer{}, []byte(text)); err != nil {
+		return "", jsonError(text, err)
+	}
+
+	// JSON is YAML written in flow style. The node keeps the order of keys.
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &document); err != nil {
+		return "", yamlError(err)
+	}
+	useBlockStyle(&document)
+
+	var converted strings.Builder
+	encoder := yaml.NewEncoder(&converted)
+	encoder.SetIndent(yamlIndentWidth(indent))
+	if err := encoder.Encode(&document); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return converted.String(), nil
+}
+
+// useBlockStyle makes a YAML node and those in it be written in the usual
+// block style, with strings only quoted when they need to be.
+func useBlockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		useBlockStyle(child)
+	}
+}
+
+func yamlToJSON(text string, indent string) (string, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(text))
+	var document yaml.Node
+	if err := decoder.Decode(&document); err != nil {
+		if err == io.EOF {
+			return "", errors.New("There is nothing to convert")
+		}
+		return "", yamlError(err)
+	}
+	var another yaml.Node
+	if err := decoder.Decode(&another); err != io.EOF {
+		if err != nil {
+			return "", yamlError(err)
+		}
+		return "", &dataError{line: another.Line, message: "JSON can only hold one document"}
+	}
+
+	var compact bytes.Buffer
+	if err := writeYAMLNodeAsJSON(&compact, &document); err != nil {
+		return "", err
+	}
+	var converted bytes.Buffer
+	if err := json.Indent(&converted, compact.Bytes(), "", indent); err != nil {
+		return "", err
+	}
+	return converted.String(), nil
+}
+
+// writeYAMLNodeAsJSON writes a YAML node as compact JSON, keeping the order
+// of keys.
+func writeYAMLNodeAsJSON(out *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			out.WriteString("null")
+			return nil
+		}
+		return writeYAMLNodeAsJSON(out, node.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLNodeAsJSON(out, node.Alias)
+	case yaml.SequenceNode:
+		out.WriteString("[")
+		for i, item := range node.Content {
+			if i != 0 {
+				out.WriteString(",")
+			}
+			if err := writeYAMLNodeAsJSON(out, item); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+		return nil
+	case yaml.MappingNode:
+		out.WriteString("{")
+		for i, pair := range yamlMappingPairs(node) {
+			key := pair[0]
+			if key.Kind == yaml.AliasNode {
+				key = key.Alias
+			}
+			if key.Kind != yaml.ScalarNode {
+				return &dataError{line: key.Line, column: key.Column, message: "JSON keys can only be text"}
+			}
+			if i != 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(jsonQuote(key.Value) + ":")
+			if err := writeYAMLNodeAsJSON(out, pair[1]); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+		return nil
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		out.WriteString("null")
+	case "!!bool", "!!int", "!!float":
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return &dataError{line: node.Line, column: node.Column, message: err.Error()}
+		}
+		if f, ok := value.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return &dataError{line: node.Line, column: node.Column, message: node.Value + " isn't a number in JSON"}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return &dataError{line: node.Line, column: node.Column, message: err.Error()}
+		}
+		out.Write(encoded)
+	default:
+		out.WriteString(jsonQuote(node.Value))
+	}
+	return nil
+}
+
+// yamlMappingPairs lists the keys and values of a mapping, with those of any
+// mappings merged in with `<<` first.
+func yamlMappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	pairs := [][2]*yaml.Node{}
+	merged := [][2]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			pairs = append(pairs, [2]*yaml.Node{key, value})
+			continue
+		}
+
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			if source.Kind == yaml.AliasNode {
+				source = source.Alias
+			}
+			if source.Kind == yaml.MappingNode {
+				merged = append(merged, yamlMappingPairs(source)...)
+			}
+		}
+	}
+
+	// Keys given in the mapping itself win over merged ones.
+	result := [][2]*yaml.Node{}
+	for _, pair := range merged {
+		overridden := false
+		for _, own := range pairs {
+			if own[0].Value == pair[0].Value {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			result = append(result, pair)
+		}
+	}
+	return append(result, pairs...)
+}
+
+func jsonQuote(s string) string {
+	escaped, _ := jsonStringEscape(s)
+	return `"` + escaped + `"`
+}
+
+// csvToMarkdownTable turns CSV, or TSV if the first line has a tab in it,
+// into a Markdown table with the first row as its header.
+func csvToMarkdownTable(text string, indent string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	firstLine, _, _ := strings.Cut(text, "\n")
+	if strings.Contains(firstLine, "\t") {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return "", &dataError{line: parseError.Line, column: parseError.Column, message: parseError.Err.Error()}
+		}
+		return "", err
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	widths := make([]int, columns)
+	for r, row := range rows {
+		for c, cell := range row {
+			row[c] = markdownTableEscape(strings.ReplaceAll(strings.TrimSpace(cell), "\n", "<br>"))
+			widths[c] = max(widths[c], runewidth.StringWidth(row[c]), 3)
+		}
+		rows[r] = append(row, make([]string, columns-len(row))...)
+	}
+
+	var table strings.Builder
+	writeRow := func(cells []string) {
+		table.WriteString("|")
+		for c, cell := range cells {
+			table.WriteString(" " + runewidth.FillRight(cell, widths[c]) + " |")
+		}
+		table.WriteString("\n")
+	}
+	writeRow(rows[0])
+	delimiters := make([]string, columns)
+	for c := range delimiters {
+		delimiters[c] = strings.Repeat("-", widths[c])
+	}
+	writeRow(delimiters)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return table.String(), nil
+}
+
+// markdownTableDelimiter matches a cell in the line between the header and
+// the body of a table, like `---` or `:--:`.
+var markdownTableDelimiter = regexp.MustCompile(`^:?-+:?$`)
+
+// markdownTableToCSV turns the first Markdown table in the text into CSV.
+func markdownTableToCSV(text string, indent string) (string, error) {
+	rows := [][]string{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.Contains(line, "|") {
+			if len(rows) != 0 {
+				break
+			}
+			continue
+		}
+
+		cells := splitMarkdownTableRow(line)
+		if len(rows) == 1 && !isMarkdownTableDelimiterRow(cells) {
+			return "", &dataError{line: i + 1, message: "The line after the table header should be like | --- | --- |"}
+		}
+		if len(rows) == 1 {
+			rows = append(rows, nil) // Stands for the delimiter row
+			continue
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) < 2 {
+		return "", errors.New("There is no table with a header")
+	}
+
+	var converted strings.Builder
+	writer := csv.NewWriter(&converted)
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		for c, cell := range row {
+			row[c] = strings.ReplaceAll(cell, "<br>", "\n")
+		}
+		if err := writer.Write(row); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	return converted.String(), writer.Error()
+}
+
+// splitMarkdownTableRow splits a row like `| a | b \| c |` into its cells.
+func splitMarkdownTableRow(line string) []string {
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	cells := []string{}
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func isMarkdownTableDelimiterRow(cells []string) bool {
+	for _, cell := range cells {
+		if !markdownTableDelimiter.MatchString(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonToCSV turns a JSON array of objects into CSV, with a column for each
+// key. The columns are in the order the keys first appear in.
+func jsonToCSV(text string, indent string) (string, error) {
+	if err := json.Compact(&bytes.Buffer{}, []byte(text)); err != nil {
+		return "", jsonError(text, err)
+	}
+
+	// A single object makes one row.
+	decoder := json.NewDecoder(strings.NewReader(text))
+	inArray := strings.HasPrefix(strings.TrimSpace(text), "[")
+	if inArray {
+		decoder.Token()
+	}
+	objects := []json.RawMessage{}
+	for decoder.More() {
+		// The object starts after any white space and comma.
+		offset := int(decoder.InputOffset())
+		offset = len(text) - len(strings.TrimLeft(text[offset:], " \t\r\n,"))
+
+		var object json.RawMessage
+		if err := decoder.Decode(&object); err != nil {
+			return "", err
+		}
+		if object[0] != '{' {
+			line, column := offsetPosition(text, offset)
+			message := "That isn't an array of objects"
+			if inArray {
+				message = fmt.Sprintf("Item %d of the array isn't an object", len(objects)+1)
+			}
+			return "", &dataError{line: line, column: column, message: message}
+		}
+		objects = append(objects, object)
+		if !inArray {
+			break
+		}
+	}
+	if len(objects) == 0 {
+		return "", errors.New("There are no objects to convert")
+	}
+
+	columns := []string{}
+	seen := map[string]bool{}
+	rows := []map[string]json.RawMessage{}
+	for _, object := range objects {
+		keys := json.NewDecoder(bytes.NewReader(object))
+		keys.Token()
+		for keys.More() {
+			key, _ := keys.Token()
+			if !seen[key.(string)] {
+				seen[key.(string)] = true
+				columns = append(columns, key.(string))
+			}
+			var value json.RawMessage
+			keys.Decode(&value)
+		}
+
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(object, &row); err != nil {
+			return "", err
+		}
+		rows = append(rows, row)
+	}
+
+	var converted strings.Builder
+	writer := csv.NewWriter(&converted)
+	writer.Write(columns)
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for c, column := range columns {
+			if value, ok := row[column]; ok {
+				record[c] = csvCell(value)
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return converted.String(), writer.Error()
+}
+
+// csvCell writes a JSON value for a CSV cell. Strings lose their quotes and
+// arrays and objects are kept as JSON.
+func csvCell(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, value)
+	return compact.String()
+}
@@ -17,8 +17,8 @@ func ShowInputDialog(title string, message string, defaultValue string, onCancel
 
 	if inputDialog == nil {
 		inputDialog = dialog.NewInputDialog(app)
-		inputDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
 	}
+	inputDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
 
 	width := 50
 	height := 7
@@ -2,7 +2,9 @@ package application
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"dinky/internal/application/settingstype"
+	"dinky/internal/application/textencoding"
 	"dinky/internal/tui/findbar"
 	"dinky/internal/tui/menu"
 	"dinky/internal/tui/scrollbar"
@@ -14,8 +16,10 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 
 	"runtime/debug"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/google/uuid"
@@ -23,6 +27,7 @@ import (
 	"github.com/sedwards2009/smidgen"
 	"github.com/sedwards2009/smidgen/micro/buffer"
 	"github.com/sedwards2009/smidgen/micro/display"
+	"golang.org/x/term"
 )
 
 // -----------------------------------------------------------------
@@ -52,11 +57,25 @@ type FileBuffer struct {
 	editor   *smidgen.View
 	uuid     string
 	filename string
+
+	encoding string
+
+	diskState              diskFileState
+	isDiskChangeDialogOpen bool
+
+	hasRecoveryFile bool
+	recoveryHash    [sha256.Size]byte
+
+	// Set on tabs like Find in Files results, where Enter on a line does
+	// something with it instead of editing.
+	onEnter    func() tview.Primitive
+	stopSearch func()
 }
 
 var fileBuffers []*FileBuffer
 var currentFileBuffer *FileBuffer
 
+// Most recent first
 var findbarRecentSearchTextHistory []string
 var findbarRecentReplaceTextHistory []string
 
@@ -77,7 +96,7 @@ func loadEditorColorScheme(colorSchemeName string) {
 	tabBarLine.SetTabBackgroundColor(bg)
 }
 
-func newFile(contents string, filename string) {
+func newFile(contents string, filename string) *FileBuffer {
 	buffer := smidgen.NewBufferFromString(contents, filename)
 	editor := smidgen.NewView(app, buffer)
 	buffer.Path = filename // femto uses this to determine the file type
@@ -107,6 +126,7 @@ func newFile(contents string, filename string) {
 	bufferFindbar := findbar.NewFindbar(app, editor)
 	style.StyleFindbar(bufferFindbar)
 	bufferFindbar.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+	bufferFindbar.MaxHistorySize = settings.HistorySize
 	bufferFindbar.SetSearchTextHistory(findbarRecentSearchTextHistory)
 	bufferFindbar.SetReplaceTextHistory(findbarRecentReplaceTextHistory)
 	bufferFindbar.OnSearchTextHistoryChange = func(history []string) {
@@ -134,12 +154,14 @@ func newFile(contents string, filename string) {
 		editor:   editor,
 		uuid:     uuid.New().String(),
 		filename: filename,
+		encoding: textencoding.DefaultEncoding,
 	}
 
 	fileBuffer.openFindbar = func() {
 		if !fileBuffer.isFindbarOpen {
 			fileBuffer.panelVFlex.AddItem(fileBuffer.findbar, 1, 0, false)
 			fileBuffer.isFindbarOpen = true
+			fileBuffer.findbar.StartSearch()
 		}
 
 		selectionText := editor.Cursor().GetSelection()
@@ -151,6 +173,7 @@ func newFile(contents string, filename string) {
 			}
 			fileBuffer.findbar.SetSearchText(string(selectionText))
 		}
+		fileBuffer.findbar.UpdateMatches()
 	}
 	bufferFindbar.OnClose = func() {
 		if fileBuffer.isFindbarOpen {
@@ -193,10 +216,14 @@ func newFile(contents string, filename string) {
 
 	selectTab(fileBuffer.uuid)
 	app.SetFocus(editor)
+
+	watchFileBuffer(fileBuffer)
+	return fileBuffer
 }
 
 func syncFindbarSearchTextHistory(history []string, originalFindbar *findbar.Findbar) {
 	findbarRecentSearchTextHistory = history
+	saveHistory()
 	for _, fileBuffer := range fileBuffers {
 		if fileBuffer.findbar == originalFindbar {
 			continue
@@ -207,6 +234,7 @@ func syncFindbarSearchTextHistory(history []string, originalFindbar *findbar.Fin
 
 func syncFindbarReplaceTextHistory(history []string, originalFindbar *findbar.Findbar) {
 	findbarRecentReplaceTextHistory = history
+	saveHistory()
 	for _, fileBuffer := range fileBuffers {
 		if fileBuffer.findbar == originalFindbar {
 			continue
@@ -217,15 +245,35 @@ func syncFindbarReplaceTextHistory(history []string, originalFindbar *findbar.Fi
 
 func loadFile(filename string) string {
 	// Read the file contents
-	contents, err := os.ReadFile(filename)
+	text, encodingID, err := readFileText(filename, "")
 	if err != nil {
 		newFile("", filename)
 		return fmt.Sprintf("Failed to read file '%s':\n%v", filename, err)
 	}
-	newFile(string(contents), filename)
+	fileBuffer := newFile(text, filename)
+	fileBuffer.encoding = encodingID
+	syncEncoding(menus, fileBuffer.encoding)
+	recordDiskFileState(fileBuffer)
 	return ""
 }
 
+// findFileBufferByFilename returns the open buffer for a file, if any.
+func findFileBufferByFilename(filename string) *FileBuffer {
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil
+	}
+	for _, fileBuffer := range fileBuffers {
+		if fileBuffer.filename == "" {
+			continue
+		}
+		if bufferFilename, err := filepath.Abs(fileBuffer.filename); err == nil && bufferFilename == absFilename {
+			return fileBuffer
+		}
+	}
+	return nil
+}
+
 func getFileBufferByID(id string) *FileBuffer {
 	for _, fileBuffer := range fileBuffers {
 		if fileBuffer.uuid == id {
@@ -241,6 +289,7 @@ func showTabPage(id string) {
 	editorPages.SwitchToPage(id)
 	currentFileBuffer = fileBuffer
 	syncMenuFromBuffer(currentFileBuffer.buffer)
+	syncEncoding(menus, currentFileBuffer.encoding)
 }
 
 func selectTab(id string) {
@@ -268,6 +317,7 @@ func syncStatusBarFromFileBuffer(statusBar *statusbar.StatusBar) {
 		lineEndings = "CRLF"
 	}
 	statusBar.LineEndings = lineEndings
+	statusBar.Encoding = textencoding.Title(fileBuffer.encoding)
 }
 
 func isBufferCRLF(buffer *buffer.Buffer) bool {
@@ -275,6 +325,17 @@ func isBufferCRLF(buffer *buffer.Buffer) bool {
 }
 
 func editorInputCapture(event *tcell.EventKey) *tcell.EventKey {
+	if handleChordKey(event) {
+		return nil
+	}
+
+	if event.Key() == tcell.KeyEnter && event.Modifiers() == tcell.ModNone && currentFileBuffer.onEnter != nil {
+		if p := currentFileBuffer.onEnter(); p != nil {
+			app.SetFocus(p)
+		}
+		return nil
+	}
+
 	for keyDesc, action := range dinkyKeyBindings {
 		if event.Key() == keyDesc.KeyCode {
 			if event.Key() == tcell.KeyRune && keyDesc.R != event.Rune() {
@@ -295,13 +356,21 @@ func editorInputCapture(event *tcell.EventKey) *tcell.EventKey {
 
 func showHelp() {
 	fmt.Printf("Dinky - A little text editor\n\n")
-	fmt.Printf("Usage: dinky [options] [file1] [file2] ...\n\n")
+	fmt.Printf("Usage: dinky [options] [+line[:col]] [file1[:line[:col]]] [file2] ...\n")
+	fmt.Printf("       some-command | dinky [options] -\n\n")
 	fmt.Printf("Options:\n")
 	fmt.Printf("  -h, --help     Show this help message and exit\n")
 	fmt.Printf("  -v, --version  Show version information and exit\n")
-	fmt.Printf("  --log          Enable logging to app.log file\n\n")
+	fmt.Printf("  --log          Enable logging to app.log file\n")
+	fmt.Printf("  --restore      Reopen the files from the last session\n")
+	fmt.Printf("  --pipe         Edit stdin and write the result to stdout on exit\n")
+	fmt.Printf("  --wait         Return once the given files have been closed\n")
+	fmt.Printf("  --remote       Open the files in the Dinky which is already running\n\n")
 	fmt.Printf("Arguments:\n")
-	fmt.Printf("  file1, file2, ...  Files to open in the editor\n\n")
+	fmt.Printf("  file1, file2, ...  Files to open in the editor\n")
+	fmt.Printf("  file:line[:col]    Open a file with the cursor at a line and column\n")
+	fmt.Printf("  +line[:col] file   Same as above, in the style used by git and others\n")
+	fmt.Printf("  -                  Read the contents of stdin into a new buffer\n\n")
 	fmt.Printf("If no files are specified, a new empty file will be created.\n")
 }
 
@@ -354,7 +423,8 @@ func getDinkyVersionTime() string {
 
 func parseCommandLine() bool {
 	args := os.Args[1:]
-	fileArgs := []string{}
+	fileArgs := []fileArgument{}
+	position := ""
 
 	for _, arg := range args {
 		switch arg {
@@ -366,7 +436,22 @@ func parseCommandLine() bool {
 			return false
 		case "--log":
 			enableLogging = true
+		case "--restore":
+			restoreSession = true
+		case "-":
+			readStdin = true
+		case "--pipe":
+			pipeMode = true
+		case "--wait":
+			waitMode = true
+		case "--remote":
+			remoteMode = true
 		default:
+			// `+line` or `+line:col` applies to the file which follows it
+			if len(arg) > 1 && arg[0] == '+' && linePositionRegex.MatchString(arg[1:]) {
+				position = arg[1:]
+				continue
+			}
 			// If it starts with a dash, it's an unknown option
 			if len(arg) > 0 && arg[0] == '-' {
 				fmt.Fprintf(os.Stderr, "Error: Unknown option '%s'\n", arg)
@@ -374,12 +459,16 @@ func parseCommandLine() bool {
 				return false
 			}
 			// Otherwise, it's a file to open
-			fileArgs = append(fileArgs, arg)
+			fileArgs = append(fileArgs, fileArgument{filename: arg, position: position})
+			position = ""
 		}
 	}
 
-	// Update os.Args to contain only the program name and file arguments
-	os.Args = append([]string{os.Args[0]}, fileArgs...)
+	if pipeMode && !term.IsTerminal(int(os.Stdin.Fd())) {
+		readStdin = true
+	}
+
+	fileArguments = fileArgs
 
 	return true
 }
@@ -390,6 +479,10 @@ func Main() {
 		return
 	}
 
+	if remoteMode && !readStdin && runRemoteClient() {
+		return
+	}
+
 	var logFile *os.File
 	if enableLogging {
 		logFile = setupLogging()
@@ -400,13 +493,39 @@ func Main() {
 		log.SetOutput(io.Discard)
 	}
 
+	var stdinText, stdinEncoding string
+	if readStdin {
+		var err error
+		stdinText, stdinEncoding, err = readStdinContents()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			return
+		}
+	}
+
 	settings = LoadUserSettings()
+	loadHistory()
 
-	initKeyBindings()
+	keyBindingProblems := loadUserKeyBindings()
+	keyBindingProblems = append(keyBindingProblems, initKeyBindings()...)
 
 	app = tview.NewApplication()
 	tview.DoubleClickInterval = 0 // Disable tview's double-click handling
 	app.EnableMouse(true)
+	if isRedirected() {
+		screen, err := newTtyScreen()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening terminal: %v\n", err)
+			return
+		}
+		app.SetScreen(screen)
+	}
+
+	initFileWatcher()
+	defer fileWatcher.Close()
+
+	startRemoteServer()
+	defer stopRemoteServer()
 
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		// Disable Ctrl-C quitting the app
@@ -436,7 +555,9 @@ func Main() {
 	}
 	tabBarLine.OnTabCloseClick = func(id string, index int) {
 		fileBufferID = id
-		handleCloseFile()
+		if nextFocus := handleCloseFile(); nextFocus != nil {
+			app.SetFocus(nextFocus)
+		}
 	}
 
 	loadEditorColorScheme(settings.ColorScheme)
@@ -462,13 +583,35 @@ func Main() {
 		}
 	})
 
+	// The tab to start on is the last file from the command line, or stdin,
+	// or else the one which was active in the session.
+	var startFileBuffer *FileBuffer
 	errorMessages := []string{}
-	for _, arg := range os.Args[1:] {
-		resultString := loadFile(arg)
+	if (restoreSession || settings.RestoreSession) && !pipeMode {
+		startFileBuffer, errorMessages = loadSession()
+	}
+	if readStdin {
+		loadStdinFileBuffer(stdinText, stdinEncoding)
+		startFileBuffer = stdinFileBuffer
+	}
+	waitFileBufferIDs := []string{}
+	for _, fileArg := range fileArguments {
+		fileBuffer, resultString := openFileArgument(fileArg)
 		if resultString != "" {
 			errorMessages = append(errorMessages, resultString)
 		}
+		waitFileBufferIDs = append(waitFileBufferIDs, fileBuffer.uuid)
+		startFileBuffer = fileBuffer
+	}
+	waitForFileArguments(waitFileBufferIDs)
+
+	if len(fileBuffers) == 0 {
+		newFile("", "")
+	}
+	if startFileBuffer == nil {
+		startFileBuffer = fileBuffers[0]
 	}
+	selectTab(startFileBuffer.uuid)
 
 	var showLoadingError func()
 	showLoadingError = func() {
@@ -476,19 +619,33 @@ func Main() {
 		if len(errorMessages) > 0 {
 			errorMessage := errorMessages[0]
 			errorMessages = errorMessages[1:]
-			ShowOkDialog("Error loading file", errorMessage, showLoadingError)
+			app.SetFocus(ShowOkDialog("Error loading file", errorMessage, showLoadingError))
+		} else if len(keyBindingProblems) > 0 {
+			message := "These key binding problems were skipped over:\n\n" +
+				strings.Join(keyBindingProblems, "\n")
+			keyBindingProblems = nil
+			app.SetFocus(ShowOkDialog("Key Bindings", message, showLoadingError))
+		} else if recoveryDialog := showRecoveryDialog(); recoveryDialog != nil {
+			app.SetFocus(recoveryDialog)
+		} else {
+			app.SetFocus(currentFileBuffer.editor)
 		}
 	}
 	showLoadingError()
 
-	if len(fileBuffers) == 0 {
-		newFile("", "")
-	}
-	selectTab(fileBuffers[0].uuid)
+	startRecoveryAutosave()
+	defer func() {
+		// Rescue unsaved edits before the panic takes us down.
+		if r := recover(); r != nil {
+			saveRecoveryFiles()
+			panic(r)
+		}
+	}()
 
 	if err := app.Run(); err != nil {
 		log.Fatalf("Application error: %v", err)
 	}
+	writePipeOutput()
 }
 
 func setupLogging() *os.File {
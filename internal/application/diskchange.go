@@ -0,0 +1,244 @@
+package application
+
+import (
+	"crypto/sha256"
+	"dinky/internal/application/filewatcher"
+	"dinky/internal/utility"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen/micro/buffer"
+)
+
+var fileWatcher filewatcher.Watcher
+
+// diskFileState records what a file looked like on disk the last time we
+// loaded or saved it. It is used to detect changes made behind our back.
+type diskFileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func readDiskFileState(filename string) diskFileState {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return diskFileState{}
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return diskFileState{}
+	}
+	return diskFileState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		hash:    sha256.Sum256(contents),
+	}
+}
+
+func recordDiskFileState(fileBuffer *FileBuffer) {
+	if fileBuffer.filename == "" {
+		fileBuffer.diskState = diskFileState{}
+		return
+	}
+	fileBuffer.diskState = readDiskFileState(fileBuffer.filename)
+}
+
+// hasChangedOnDisk returns true if the file behind a buffer is different
+// from what it was when we last loaded or saved it.
+func hasChangedOnDisk(fileBuffer *FileBuffer) bool {
+	if fileBuffer.filename == "" {
+		return false
+	}
+
+	info, err := os.Stat(fileBuffer.filename)
+	if err != nil {
+		return fileBuffer.diskState.exists
+	}
+	if fileBuffer.diskState.exists && info.Size() == fileBuffer.diskState.size &&
+		info.ModTime().Equal(fileBuffer.diskState.modTime) {
+		return false
+	}
+
+	// The timestamp moved, but the contents may still be the same (e.g. `touch`).
+	state := readDiskFileState(fileBuffer.filename)
+	if state.exists == fileBuffer.diskState.exists && state.hash == fileBuffer.diskState.hash {
+		fileBuffer.diskState = state
+		return false
+	}
+	return true
+}
+
+func initFileWatcher() {
+	fileWatcher = filewatcher.NewWatcher(func(filename string) {
+		app.QueueUpdateDraw(func() {
+			handleFileChangedOnDisk(filename)
+		})
+	})
+}
+
+func watchFileBuffer(fileBuffer *FileBuffer) {
+	if fileBuffer.filename == "" {
+		return
+	}
+	if err := fileWatcher.Add(fileBuffer.filename); err != nil {
+		statusBar.ShowWarning("Changes on disk to '" + filepath.Base(fileBuffer.filename) +
+			"' won't be noticed: " + err.Error())
+	}
+}
+
+func unwatchFileBuffer(fileBuffer *FileBuffer) {
+	if fileBuffer.filename != "" {
+		fileWatcher.Remove(fileBuffer.filename)
+	}
+}
+
+func handleFileChangedOnDisk(filename string) {
+	for _, fileBuffer := range fileBuffers {
+		if fileBuffer.filename == "" {
+			continue
+		}
+		absFilename, err := filepath.Abs(fileBuffer.filename)
+		if err != nil || absFilename != filename {
+			continue
+		}
+		checkFileBufferOnDisk(fileBuffer)
+	}
+}
+
+func checkFileBufferOnDisk(fileBuffer *FileBuffer) {
+	if getFileBufferByID(fileBuffer.uuid) == nil || fileBuffer.isDiskChangeDialogOpen {
+		return
+	}
+	if !hasChangedOnDisk(fileBuffer) {
+		return
+	}
+
+	if _, err := os.Stat(fileBuffer.filename); err != nil {
+		recordDiskFileState(fileBuffer)
+		statusBar.ShowWarning("File '" + filepath.Base(fileBuffer.filename) + "' has been deleted on disk")
+		return
+	}
+
+	// Don't trample on a message dialog which is already open. Try again later.
+	if modalPages.HasPage(messageDialogName) {
+		time.AfterFunc(time.Second, func() {
+			app.QueueUpdateDraw(func() {
+				checkFileBufferOnDisk(fileBuffer)
+			})
+		})
+		return
+	}
+
+	app.SetFocus(showFileChangedDialog(fileBuffer))
+}
+
+func showFileChangedDialog(fileBuffer *FileBuffer) tview.Primitive {
+	fileBuffer.isDiskChangeDialogOpen = true
+	selectTab(fileBuffer.uuid)
+
+	message := "'" + filepath.Base(fileBuffer.filename) + "' has been changed on disk."
+	if fileBuffer.buffer.Modified() {
+		message += "\nYou also have unsaved changes to this file."
+	}
+
+	return ShowMessageDialog("File Changed", message, []string{"Reload", "Keep Mine", "Compare"},
+		func() {
+			CloseMessageDialog()
+			fileBuffer.isDiskChangeDialogOpen = false
+			keepFileBufferContents(fileBuffer)
+		},
+		func(button string, index int) {
+			CloseMessageDialog()
+			fileBuffer.isDiskChangeDialogOpen = false
+			switch index {
+			case 0:
+				reloadFileBuffer(fileBuffer)
+			case 1:
+				keepFileBufferContents(fileBuffer)
+			case 2:
+				compareFileBufferWithDisk(fileBuffer)
+			}
+		})
+}
+
+func showSaveChangedFileDialog(fileBuffer *FileBuffer, onSaved func()) tview.Primitive {
+	selectTab(fileBuffer.uuid)
+	message := "'" + filepath.Base(fileBuffer.filename) + "' has been changed on disk since it was loaded.\n" +
+		"Overwrite it with your version?"
+	return ShowMessageDialog("File Changed", message, []string{"Overwrite", "Compare", "Cancel"},
+		func() {
+			CloseMessageDialog()
+		},
+		func(button string, index int) {
+			CloseMessageDialog()
+			switch index {
+			case 0:
+				if nextFocus := writeFileBuffer(fileBuffer, onSaved); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			case 1:
+				compareFileBufferWithDisk(fileBuffer)
+			}
+		})
+}
+
+func reloadFileBuffer(fileBuffer *FileBuffer) {
+	text, _, err := readFileText(fileBuffer.filename, fileBuffer.encoding)
+	if err != nil {
+		statusBar.ShowError("Failed to reload file: " + err.Error())
+		return
+	}
+	setBufferContents(fileBuffer.buffer, text)
+	fileBuffer.buffer.ClearModified()
+	recordDiskFileState(fileBuffer)
+	statusBar.ShowMessage("Reloaded " + fileBuffer.filename)
+}
+
+// keepFileBufferContents accepts the version on disk as seen, so that we don't
+// nag about it again. The next save will overwrite it without asking.
+func keepFileBufferContents(fileBuffer *FileBuffer) {
+	recordDiskFileState(fileBuffer)
+	statusBar.ShowMessage("Kept your version of " + filepath.Base(fileBuffer.filename))
+}
+
+func compareFileBufferWithDisk(fileBuffer *FileBuffer) {
+	text, _, err := readFileText(fileBuffer.filename, fileBuffer.encoding)
+	if err != nil {
+		statusBar.ShowError("Failed to read file: " + err.Error())
+		return
+	}
+	diskText := strings.ReplaceAll(text, "\r\n", "\n")
+	bufferText := strings.ReplaceAll(string(bufferToBytes(fileBuffer.buffer)), "\r\n", "\n")
+
+	name := filepath.Base(fileBuffer.filename)
+	diff := utility.UnifiedDiff(name+" (on disk)", name+" (in Dinky)", diskText, bufferText)
+	if diff == "" {
+		statusBar.ShowMessage("No differences found")
+		return
+	}
+
+	diffFileBuffer := newFile(diff, "")
+	diffFileBuffer.buffer.Settings["filetype"] = "patch"
+	diffFileBuffer.buffer.UpdateRules()
+	diffFileBuffer.buffer.ClearModified()
+	tabBarLine.SetTabTitle(diffFileBuffer.uuid, "Diff: "+name)
+}
+
+// setBufferContents replaces the contents of a buffer while keeping undo
+// history, and picks up the line endings used by the new contents.
+func setBufferContents(buf *buffer.Buffer, contents string) {
+	isCRLF := strings.Contains(contents, "\r\n")
+	contents = strings.ReplaceAll(contents, "\r\n", "\n")
+
+	buf.DoSetOptionNative("fileformat", "unix")
+	buf.ApplyDiff(contents)
+	if isCRLF {
+		buf.DoSetOptionNative("fileformat", "dos")
+	}
+}
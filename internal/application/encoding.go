@@ -0,0 +1,113 @@
+package application
+
+import (
+	"dinky/internal/application/textencoding"
+	"dinky/internal/tui/dialog"
+	"dinky/internal/tui/menu"
+	"os"
+
+	"github.com/rivo/tview"
+)
+
+// readFileText reads a file and decodes it to UTF-8. If encodingID is empty
+// then the encoding is detected. The encoding used is returned.
+func readFileText(filename string, encodingID string) (text string, usedEncodingID string, err error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+	if encodingID == "" {
+		encodingID = textencoding.Detect(contents)
+	}
+	text, err = textencoding.Decode(contents, encodingID)
+	if err != nil {
+		return "", "", err
+	}
+	return text, encodingID, nil
+}
+
+func handleSetEncoding() tview.Primitive {
+	fileBuffer := currentFileBuffer
+
+	items := []dialog.ListItem{}
+	for _, encoding := range textencoding.Encodings {
+		items = append(items, dialog.ListItem{Text: encoding.Title, Value: encoding.ID})
+	}
+
+	return ShowListDialog(dialog.ListDialogOptions{
+		Title:           "Encoding",
+		Message:         "Reopen the file with an encoding, or save it with a different one:",
+		Buttons:         []string{"Reopen", "Save", "Cancel"},
+		Width:           50,
+		Height:          16,
+		DefaultSelected: fileBuffer.encoding,
+		Items:           items,
+		OnCancel: func() {
+			hideListDialog()
+		},
+		OnAccept: func(value string, buttonIndex int) {
+			hideListDialog()
+			switch buttonIndex {
+			case -1, 0:
+				if nextFocus := reopenWithEncoding(fileBuffer, value); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			case 1:
+				if nextFocus := saveWithEncoding(fileBuffer, value); nextFocus != nil {
+					app.SetFocus(nextFocus)
+				}
+			}
+		},
+	})
+}
+
+// saveWithEncoding switches a buffer to another encoding and saves it. The
+// encoding is left alone if the text can't be written in the new one.
+func saveWithEncoding(fileBuffer *FileBuffer, encodingID string) tview.Primitive {
+	if _, err := textencoding.Encode(bufferToBytes(fileBuffer.buffer), encodingID); err != nil {
+		statusBar.ShowError("Can't save as " + textencoding.Title(encodingID) + ": " + err.Error())
+		return nil
+	}
+	fileBuffer.encoding = encodingID
+	syncEncoding(menus, fileBuffer.encoding)
+	return saveFileBuffer(fileBuffer, nil)
+}
+
+func reopenWithEncoding(fileBuffer *FileBuffer, encodingID string) tview.Primitive {
+	if fileBuffer.filename == "" {
+		fileBuffer.encoding = encodingID
+		syncEncoding(menus, fileBuffer.encoding)
+		statusBar.ShowMessage("Encoding set to " + textencoding.Title(encodingID))
+		return nil
+	}
+
+	reopen := func() {
+		text, _, err := readFileText(fileBuffer.filename, encodingID)
+		if err != nil {
+			statusBar.ShowError("Failed to reopen file: " + err.Error())
+			return
+		}
+		setBufferContents(fileBuffer.buffer, text)
+		fileBuffer.buffer.ClearModified()
+		fileBuffer.encoding = encodingID
+		recordDiskFileState(fileBuffer)
+		syncEncoding(menus, fileBuffer.encoding)
+		statusBar.ShowMessage("Reopened with encoding " + textencoding.Title(encodingID))
+	}
+
+	if !fileBuffer.buffer.Modified() {
+		reopen()
+		return nil
+	}
+	return ShowConfirmDialog("Reopening the file will discard your unsaved changes. Continue?", reopen, func() {})
+}
+
+func syncEncoding(menus []*menu.Menu, encodingID string) {
+	for _, menu := range menus {
+		for _, menuItem := range menu.Items {
+			if menuItem.ID == ACTION_SET_ENCODING {
+				menuItem.Title = "Encoding (" + textencoding.Title(encodingID) + ")…"
+			}
+		}
+	}
+}
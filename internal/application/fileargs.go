@@ -0,0 +1,75 @@
+package application
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// fileArgument is a file given on the command line, with an optional
+// "line" or "line:column" position to jump to.
+type fileArgument struct {
+	filename string
+	position string
+}
+
+var fileArguments []fileArgument
+
+var linePositionRegex = regexp.MustCompile(`^\d+(:\d+)?$`)
+
+// filePositionRegexes match `file:line:col` and `file:line`, plus the
+// trailing colon which compilers and grep like to add.
+var filePositionRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`^(.+):(\d+:\d+):?$`),
+	regexp.MustCompile(`^(.+):(\d+):?$`),
+}
+
+// splitFilePosition splits a `file:line[:col]` argument into its filename and
+// position. Names of files which really exist are left alone, colons and all.
+// A file which doesn't exist yet gets split too, if its directory exists.
+func splitFilePosition(arg string) (filename string, position string) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, ""
+	}
+	for _, regex := range filePositionRegexes {
+		matches := regex.FindStringSubmatch(arg)
+		if matches == nil {
+			continue
+		}
+		if _, err := os.Stat(matches[1]); err == nil {
+			return matches[1], matches[2]
+		}
+		if info, err := os.Stat(filepath.Dir(matches[1])); err == nil && info.IsDir() {
+			return matches[1], matches[2]
+		}
+	}
+	return arg, ""
+}
+
+// openFileArgument opens or switches to a file from the command line and
+// moves the cursor to its position. An error message is also returned if
+// the file couldn't be loaded.
+func openFileArgument(fileArg fileArgument) (*FileBuffer, string) {
+	filename, position := fileArg.filename, fileArg.position
+	if position == "" {
+		filename, position = splitFilePosition(filename)
+	}
+
+	resultString := ""
+	fileBuffer := findFileBufferByFilename(filename)
+	if fileBuffer != nil {
+		selectTab(fileBuffer.uuid)
+	} else {
+		resultString = loadFile(filename)
+		fileBuffer = fileBuffers[len(fileBuffers)-1]
+	}
+
+	if position != "" {
+		if lineNum, colNum, err := parseLineColumn(position); err == nil {
+			// Be forgiving about lines past the end, the file may have changed.
+			lineNum = min(max(lineNum, 1), fileBuffer.buffer.LinesNum())
+			goToLineColumn(fileBuffer, lineNum, colNum)
+		}
+	}
+	return fileBuffer, resultString
+}
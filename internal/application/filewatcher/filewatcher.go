@@ -0,0 +1,34 @@
+package filewatcher
+
+import (
+	"path/filepath"
+)
+
+// Watcher reports when any of a set of files changes on disk.
+//
+// The change callback is called from a background goroutine. Callers which
+// touch the UI must hand the work over to the UI goroutine themselves.
+type Watcher interface {
+	// Add starts watching a file. Adding the same file twice is allowed and
+	// must be matched by the same number of calls to Remove.
+	Add(filename string) error
+	Remove(filename string)
+	Close()
+}
+
+// NewWatcher creates the best available Watcher for this platform.
+func NewWatcher(onChange func(filename string)) Watcher {
+	watcher, err := newPlatformWatcher(onChange)
+	if err != nil {
+		return newPollingWatcher(onChange)
+	}
+	return watcher
+}
+
+func absPath(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filepath.Clean(filename)
+	}
+	return abs
+}
@@ -0,0 +1,176 @@
+//go:build linux
+
+package filewatcher
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The directory containing a file is watched instead of the file itself.
+// Tools like git and most formatters replace a file by renaming a new one
+// over the top of it, which silently ends an inotify watch on the old inode.
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM
+
+type inotifyWatcher struct {
+	mutex     sync.Mutex
+	fd        int
+	files     map[string]int // Absolute filename -> reference count
+	dirs      map[string]int // Directory -> watch descriptor
+	dirCounts map[string]int // Directory -> number of watched files inside it
+	wdToDir   map[int]string
+	onChange  func(filename string)
+
+	// Files which inotify refused to watch (e.g. the watch limit was hit)
+	// are handed to a polling watcher instead.
+	poller *pollingWatcher
+	polled map[string]int // Absolute filename -> reference count
+}
+
+func newPlatformWatcher(onChange func(filename string)) (Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		fd:        fd,
+		files:     make(map[string]int),
+		dirs:      make(map[string]int),
+		dirCounts: make(map[string]int),
+		wdToDir:   make(map[int]string),
+		onChange:  onChange,
+		polled:    make(map[string]int),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(filename string) error {
+	filename = absPath(filename)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if count, ok := w.files[filename]; ok {
+		w.files[filename] = count + 1
+		return nil
+	}
+	if _, ok := w.polled[filename]; ok {
+		return w.addPolled(filename)
+	}
+
+	dir := filepath.Dir(filename)
+	if _, ok := w.dirs[dir]; !ok {
+		wd, err := unix.InotifyAddWatch(w.fd, dir, inotifyMask)
+		if err != nil {
+			return w.addPolled(filename)
+		}
+		w.dirs[dir] = wd
+		w.wdToDir[wd] = dir
+	}
+	w.dirCounts[dir]++
+	w.files[filename] = 1
+	return nil
+}
+
+func (w *inotifyWatcher) addPolled(filename string) error {
+	if w.poller == nil {
+		w.poller = newPollingWatcher(w.onChange)
+	}
+	if err := w.poller.Add(filename); err != nil {
+		return err
+	}
+	w.polled[filename]++
+	return nil
+}
+
+func (w *inotifyWatcher) Remove(filename string) {
+	filename = absPath(filename)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if count, ok := w.polled[filename]; ok {
+		w.poller.Remove(filename)
+		if count > 1 {
+			w.polled[filename] = count - 1
+		} else {
+			delete(w.polled, filename)
+		}
+		return
+	}
+
+	count, ok := w.files[filename]
+	if !ok {
+		return
+	}
+	if count > 1 {
+		w.files[filename] = count - 1
+		return
+	}
+	delete(w.files, filename)
+
+	dir := filepath.Dir(filename)
+	w.dirCounts[dir]--
+	if w.dirCounts[dir] <= 0 {
+		wd := w.dirs[dir]
+		unix.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.dirs, dir)
+		delete(w.dirCounts, dir)
+		delete(w.wdToDir, wd)
+	}
+}
+
+func (w *inotifyWatcher) Close() {
+	unix.Close(w.fd)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.poller != nil {
+		w.poller.Close()
+	}
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return
+		}
+
+		changed := map[string]bool{}
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= n {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			if filename, ok := w.watchedFilename(int(event.Wd), name); ok {
+				changed[filename] = true
+			}
+		}
+
+		for filename := range changed {
+			w.onChange(filename)
+		}
+	}
+}
+
+func (w *inotifyWatcher) watchedFilename(wd int, name string) (string, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	dir, ok := w.wdToDir[wd]
+	if !ok || name == "" {
+		return "", false
+	}
+	filename := filepath.Join(dir, name)
+	_, ok = w.files[filename]
+	return filename, ok
+}
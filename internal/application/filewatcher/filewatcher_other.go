@@ -0,0 +1,7 @@
+//go:build !linux
+
+package filewatcher
+
+func newPlatformWatcher(onChange func(filename string)) (Watcher, error) {
+	return newPollingWatcher(onChange), nil
+}
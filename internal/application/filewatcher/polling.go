@@ -0,0 +1,109 @@
+package filewatcher
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const pollInterval = 2 * time.Second
+
+type pollState struct {
+	refCount int
+	exists   bool
+	modTime  time.Time
+	size     int64
+}
+
+// pollingWatcher is the fallback Watcher which periodically stats each file.
+type pollingWatcher struct {
+	mutex    sync.Mutex
+	files    map[string]*pollState
+	onChange func(filename string)
+	stop     chan struct{}
+}
+
+func newPollingWatcher(onChange func(filename string)) *pollingWatcher {
+	w := &pollingWatcher{
+		files:    make(map[string]*pollState),
+		onChange: onChange,
+		stop:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *pollingWatcher) Add(filename string) error {
+	filename = absPath(filename)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if state, ok := w.files[filename]; ok {
+		state.refCount++
+		return nil
+	}
+	state := &pollState{refCount: 1}
+	updatePollState(filename, state)
+	w.files[filename] = state
+	return nil
+}
+
+func (w *pollingWatcher) Remove(filename string) {
+	filename = absPath(filename)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if state, ok := w.files[filename]; ok {
+		state.refCount--
+		if state.refCount <= 0 {
+			delete(w.files, filename)
+		}
+	}
+}
+
+func (w *pollingWatcher) Close() {
+	close(w.stop)
+}
+
+func (w *pollingWatcher) run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			for _, filename := range w.poll() {
+				w.onChange(filename)
+			}
+		}
+	}
+}
+
+// poll returns the names of the files which changed since the last poll.
+func (w *pollingWatcher) poll() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	changed := []string{}
+	for filename, state := range w.files {
+		if updatePollState(filename, state) {
+			changed = append(changed, filename)
+		}
+	}
+	return changed
+}
+
+func updatePollState(filename string, state *pollState) (changed bool) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		changed = state.exists
+		state.exists = false
+		return changed
+	}
+	changed = !state.exists || !info.ModTime().Equal(state.modTime) || info.Size() != state.size
+	state.exists = true
+	state.modTime = info.ModTime()
+	state.size = info.Size()
+	return changed
+}
@@ -2,12 +2,14 @@ package filtercommandaction
 
 import (
 	"bytes"
+	"dinky/internal/application/selections"
 	"dinky/internal/tui/filterdialog"
+	"dinky/internal/tui/smidgeninputfield"
 	"dinky/internal/tui/statusbar"
 	"dinky/internal/tui/style"
+	"fmt"
 	"os"
 	"os/exec"
-	"slices"
 	"strings"
 
 	"github.com/rivo/tview"
@@ -18,18 +20,37 @@ var filterDialog *filterdialog.FilterDialog
 
 const filterDialogName = "filterDialog"
 
+// Most recent first
 var recentFilterCommands []string
 var recentFilterDirectories []string
+var maxHistorySize = 10
+
+// OnHistoryChange is called when a command is run or a history entry is
+// deleted, so that the history can be saved.
+var OnHistoryChange func(commands []string, directories []string)
+
+// SetHistory sets the recent commands and directories, most recent first.
+func SetHistory(commands []string, directories []string, maxSize int) {
+	recentFilterCommands = commands
+	recentFilterDirectories = directories
+	maxHistorySize = maxSize
+}
+
+func notifyHistoryChange() {
+	if OnHistoryChange != nil {
+		OnHistoryChange(recentFilterCommands, recentFilterDirectories)
+	}
+}
 
 func HandleFilterExternalCommand(app *tview.Application, modalPages *tview.Pages, editor *smidgen.View,
 	smidgenSingleLineKeyBindings smidgen.Keybindings, statusBar *statusbar.StatusBar) tview.Primitive {
 
-	if !editor.Cursor().HasSelection() {
+	if !selections.HasSelection(editor) {
 		statusBar.ShowWarning("No text selected")
 		return nil
 	}
 
-	return showFilterDialog(app, modalPages, smidgenSingleLineKeyBindings, recentFilterCommands,
+	return showFilterDialog(app, modalPages, smidgenSingleLineKeyBindings,
 		func() {
 			// On cancel
 			closeFilterDialog(modalPages)
@@ -40,21 +61,10 @@ func HandleFilterExternalCommand(app *tview.Application, modalPages *tview.Pages
 				return
 			}
 
-			if !slices.Contains(recentFilterCommands, command) {
-				recentFilterCommands = append(recentFilterCommands, command)
-				if len(recentFilterCommands) > 10 {
-					recentFilterCommands = recentFilterCommands[1:]
-				}
-			}
-
-			if !slices.Contains(recentFilterDirectories, directory) {
-				recentFilterDirectories = append(recentFilterDirectories, directory)
-				if len(recentFilterDirectories) > 10 {
-					recentFilterDirectories = recentFilterDirectories[1:]
-				}
-			}
-
-			selectionBytes := editor.Cursor().GetSelection()
+			recentFilterCommands = smidgeninputfield.AddToHistory(recentFilterCommands, command, maxHistorySize)
+			recentFilterDirectories = smidgeninputfield.AddToHistory(recentFilterDirectories, directory,
+				maxHistorySize)
+			notifyHistoryChange()
 
 			// Check if the directory is ok before trying to run the command.
 			if directory != "" {
@@ -70,27 +80,37 @@ func HandleFilterExternalCommand(app *tview.Application, modalPages *tview.Pages
 				}
 			}
 
-			// Run external command with selection as stdin
-			output, err := runExternalShellCommandWithInput(command, directory, selectionBytes)
+			// Run external command with each selection as stdin. If it fails on any
+			// of them then the text is left alone.
+			err := selections.Replace(editor, func(text string) (string, error) {
+				output, err := runExternalShellCommandWithInput(command, directory, []byte(text))
+				if err != nil {
+					return "", err
+				}
+				return strings.TrimRight(string(output), "\n"), nil
+			})
 			if err != nil {
 				statusBar.ShowError("Error running shell command: " + err.Error())
 			}
-
-			editor.ActionController().TransformSelection(func(lines []string) []string {
-				stringOutput := string(output)
-				return strings.Split(strings.TrimRight(stringOutput, "\n"), "\n")
-			})
 		})
 }
 
 func showFilterDialog(app *tview.Application, modalPages *tview.Pages,
-	smidgenSingleLineKeyBindings smidgen.Keybindings, recentFilterCommands []string,
+	smidgenSingleLineKeyBindings smidgen.Keybindings,
 	onCancel func(), onAccept func(command string, directory string, index int)) tview.Primitive {
 
 	if filterDialog == nil {
 		filterDialog = filterdialog.NewFilterDialog(app)
-		filterDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+		filterDialog.CommandInputField.SetHistoryChangedFunc(func(history []string) {
+			recentFilterCommands = history
+			notifyHistoryChange()
+		})
+		filterDialog.DirectoryInputField.SetHistoryChangedFunc(func(history []string) {
+			recentFilterDirectories = history
+			notifyHistoryChange()
+		})
 	}
+	filterDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
 	filterDialog.SetRecentCommands(recentFilterCommands)
 	filterDialog.SetRecentDirectories(recentFilterDirectories)
 	modalPages.AddPage(filterDialogName, filterDialog, true, true)
@@ -125,7 +145,11 @@ func runExternalShellCommandWithInput(command string, directory string, input []
 	cmd.Stderr = &stderr
 	err := cmd.Run()
 	if err != nil {
-		return append(out.Bytes(), stderr.Bytes()...), err
+		message := strings.TrimSpace(stderr.String())
+		if message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
 	}
-	return out.Bytes(), err
+	return out.Bytes(), nil
 }
@@ -0,0 +1,475 @@
+package application
+
+import (
+	"context"
+	"dinky/internal/application/findinfiles"
+	"dinky/internal/application/textencoding"
+	"dinky/internal/tui/dialog"
+	"dinky/internal/tui/findinfilesdialog"
+	"dinky/internal/tui/smidgeninputfield"
+	"dinky/internal/tui/style"
+	"dinky/internal/utility"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+const findInFilesDialogName = "findInFilesDialog"
+const maxFindInFilesMatches = 10000
+
+var findInFilesDialog *findinfilesdialog.FindInFilesDialog
+
+// Most recent first
+var recentFindInFilesSearches []string
+var recentFindInFilesReplacements []string
+var recentFindInFilesDirectories []string
+
+// searchResultLineRegex picks apart the `file:line:col: text` lines in a
+// Find in Files results tab.
+var searchResultLineRegex = regexp.MustCompile(`^(.+?):(\d+):(\d+): `)
+
+// replaceInFilesChange is the result of a replace on one file, ready to be
+// applied once it has been previewed.
+type replaceInFilesChange struct {
+	filename   string      // Absolute
+	relPath    string      // As shown to the user
+	fileBuffer *FileBuffer // nil if the file isn't open
+	encoding   string      // For files which aren't open
+	original   string
+	replaced   string
+	count      int
+}
+
+func handleFindInFiles() tview.Primitive {
+	return showFindInFilesDialog(false)
+}
+
+func handleReplaceInFiles() tview.Primitive {
+	return showFindInFilesDialog(true)
+}
+
+func showFindInFilesDialog(replace bool) tview.Primitive {
+	if findInFilesDialog == nil {
+		findInFilesDialog = findinfilesdialog.NewFindInFilesDialog(app)
+		findInFilesDialog.SearchInputField.SetHistoryChangedFunc(func(history []string) {
+			recentFindInFilesSearches = history
+			saveHistory()
+		})
+		findInFilesDialog.ReplaceInputField.SetHistoryChangedFunc(func(history []string) {
+			recentFindInFilesReplacements = history
+			saveHistory()
+		})
+		findInFilesDialog.DirectoryInputField.SetHistoryChangedFunc(func(history []string) {
+			recentFindInFilesDirectories = history
+			saveHistory()
+		})
+	}
+	findInFilesDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+
+	searchText := string(currentFileBuffer.editor.Cursor().GetSelection())
+	if searchText == "" || strings.Contains(searchText, "\n") {
+		searchText = currentFileBuffer.findbar.SearchStringField.GetText()
+	}
+	if searchText != "" {
+		findInFilesDialog.SetSearchText(searchText)
+	}
+	findInFilesDialog.SetRecentSearches(recentFindInFilesSearches)
+	findInFilesDialog.SetRecentReplacements(recentFindInFilesReplacements)
+	findInFilesDialog.SetRecentDirectories(recentFindInFilesDirectories)
+
+	modalPages.AddPage(findInFilesDialogName, findInFilesDialog, true, true)
+	findInFilesDialog.Open(findinfilesdialog.FindInFilesDialogOptions{
+		Replace: replace,
+		OnCancel: func() {
+			closeFindInFilesDialog()
+		},
+		OnAccept: func(query findinfilesdialog.Query, index int) {
+			if index == 1 {
+				closeFindInFilesDialog()
+				return
+			}
+			if query.SearchText == "" {
+				statusBar.ShowWarning("Enter some text to find")
+				return
+			}
+			options, err := findInFilesOptions(query)
+			if err != nil {
+				statusBar.ShowError(err.Error())
+				return
+			}
+			closeFindInFilesDialog()
+
+			recentFindInFilesSearches = smidgeninputfield.AddToHistory(recentFindInFilesSearches, query.SearchText,
+				settings.HistorySize)
+			recentFindInFilesDirectories = smidgeninputfield.AddToHistory(recentFindInFilesDirectories,
+				query.Directory, settings.HistorySize)
+			if replace {
+				recentFindInFilesReplacements = smidgeninputfield.AddToHistory(recentFindInFilesReplacements,
+					query.ReplaceText, settings.HistorySize)
+			}
+			saveHistory()
+			if replace {
+				startReplaceInFiles(query, options)
+			} else {
+				startFindInFiles(query, options)
+			}
+		},
+	})
+	style.StyleFindInFilesDialog(findInFilesDialog)
+	return findInFilesDialog
+}
+
+func closeFindInFilesDialog() {
+	if findInFilesDialog != nil {
+		findInFilesDialog.Close()
+		modalPages.RemovePage(findInFilesDialogName)
+	}
+}
+
+func findInFilesOptions(query findinfilesdialog.Query) (findinfiles.Options, error) {
+	root := query.Directory
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return findinfiles.Options{}, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return findinfiles.Options{}, fmt.Errorf("Directory '%s' isn't valid", query.Directory)
+	}
+
+	pattern, err := searchRegexp(query.SearchText, query.Regex, query.CaseSensitive)
+	if err != nil {
+		return findinfiles.Options{}, err
+	}
+
+	// Search what is in the open buffers, not what was last saved.
+	contents := map[string][]byte{}
+	for _, fileBuffer := range fileBuffers {
+		if fileBuffer.filename == "" {
+			continue
+		}
+		if absFilename, err := filepath.Abs(fileBuffer.filename); err == nil {
+			contents[absFilename] = bufferToBytes(fileBuffer.buffer)
+		}
+	}
+
+	return findinfiles.Options{
+		Root:     root,
+		Pattern:  pattern,
+		Include:  findinfiles.ParseGlobs(query.Include),
+		Exclude:  findinfiles.ParseGlobs(query.Exclude),
+		Contents: contents,
+	}, nil
+}
+
+// newSpecialFileBuffer opens a tab for search results and the like, which
+// doesn't nag about being saved.
+func newSpecialFileBuffer(title string, contents string, fileType string) *FileBuffer {
+	fileBuffer := newFile(contents, "")
+	if fileType != "" {
+		fileBuffer.buffer.Settings["filetype"] = fileType
+		fileBuffer.buffer.UpdateRules()
+	}
+	fileBuffer.buffer.ClearModified()
+	tabBarLine.SetTabTitle(fileBuffer.uuid, title)
+	return fileBuffer
+}
+
+func appendToFileBuffer(fileBuffer *FileBuffer, text string) {
+	buf := fileBuffer.buffer
+	buf.Insert(buf.End(), text)
+	buf.ClearModified()
+}
+
+func startFindInFiles(query findinfilesdialog.Query, options findinfiles.Options) {
+	header := fmt.Sprintf("Find in Files: '%s' in %s\nPress Enter on a result to open it.\n\n", query.SearchText,
+		options.Root)
+	resultsFileBuffer := newSpecialFileBuffer("Find: "+query.SearchText, header, "")
+	resultsFileBuffer.onEnter = func() tview.Primitive {
+		return openSearchResult(resultsFileBuffer, options.Root)
+	}
+	app.SetFocus(resultsFileBuffer.editor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultsFileBuffer.stopSearch = cancel
+	statusBar.ShowMessage("Searching…")
+
+	go func() {
+		matchCount := 0
+		fileCount := 0
+		err := findinfiles.Search(ctx, options, func(fileMatches findinfiles.FileMatches) {
+			if matchCount >= maxFindInFilesMatches {
+				cancel()
+				return
+			}
+			fileCount++
+			var sb strings.Builder
+			for _, match := range fileMatches.Matches {
+				fmt.Fprintf(&sb, "%s:%d:%d: %s\n", fileMatches.Filename, match.Line+1, match.Start+1, match.Text)
+				matchCount++
+			}
+			text := sb.String()
+			app.QueueUpdateDraw(func() {
+				if !isSearchShowing(resultsFileBuffer) {
+					return
+				}
+				appendToFileBuffer(resultsFileBuffer, text)
+			})
+		})
+
+		summary := fmt.Sprintf("%d matches in %d files", matchCount, fileCount)
+		if matchCount >= maxFindInFilesMatches {
+			summary = fmt.Sprintf("Stopped after %d matches in %d files", matchCount, fileCount)
+		} else if err != nil && err != context.Canceled {
+			summary = "Search failed: " + err.Error()
+		}
+		app.QueueUpdateDraw(func() {
+			if !isSearchShowing(resultsFileBuffer) {
+				return // The results tab was closed or saved
+			}
+			resultsFileBuffer.stopSearch = nil
+			appendToFileBuffer(resultsFileBuffer, "\n"+summary+"\n")
+			statusBar.ShowMessage(summary)
+		})
+	}()
+}
+
+// isSearchShowing returns true if the search results are still wanted in
+// resultsFileBuffer.
+func isSearchShowing(resultsFileBuffer *FileBuffer) bool {
+	return resultsFileBuffer.stopSearch != nil && getFileBufferByID(resultsFileBuffer.uuid) != nil
+}
+
+// stopSearch stops any search which is still adding results to a buffer.
+func stopSearch(fileBuffer *FileBuffer) {
+	if fileBuffer.stopSearch != nil {
+		fileBuffer.stopSearch()
+		fileBuffer.stopSearch = nil
+	}
+}
+
+// openSearchResult opens the file and position named on the cursor line of
+// a results tab.
+func openSearchResult(resultsFileBuffer *FileBuffer, root string) tview.Primitive {
+	line := resultsFileBuffer.buffer.Line(resultsFileBuffer.editor.Cursor().Y)
+	matches := searchResultLineRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+
+	filename := filepath.Join(root, filepath.FromSlash(matches[1]))
+	fileBuffer, resultString := openFileArgument(fileArgument{filename: filename, position: matches[2] + ":" + matches[3]})
+	if resultString != "" {
+		statusBar.ShowError(resultString)
+	}
+	return fileBuffer.editor
+}
+
+func startReplaceInFiles(query findinfilesdialog.Query, options findinfiles.Options) {
+	ctx, cancel := context.WithCancel(context.Background())
+	statusBar.ShowMessage("Searching…")
+
+	go func() {
+		defer cancel()
+		allMatches := []findinfiles.FileMatches{}
+		err := findinfiles.Search(ctx, options, func(fileMatches findinfiles.FileMatches) {
+			allMatches = append(allMatches, fileMatches)
+		})
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				statusBar.ShowError("Search failed: " + err.Error())
+				return
+			}
+			showReplaceInFilesPreview(query, options, allMatches)
+		})
+	}()
+}
+
+func showReplaceInFilesPreview(query findinfilesdialog.Query, options findinfiles.Options,
+	allMatches []findinfiles.FileMatches) {
+
+	slices.SortFunc(allMatches, func(a, b findinfiles.FileMatches) int {
+		return strings.Compare(a.Filename, b.Filename)
+	})
+
+	changes := []*replaceInFilesChange{}
+	for _, fileMatches := range allMatches {
+		change, err := prepareReplaceInFile(options, fileMatches.Filename, query.ReplaceText, query.Regex)
+		if err != nil {
+			statusBar.ShowError(err.Error())
+			continue
+		}
+		if change.count != 0 {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		statusBar.ShowWarning("'" + query.SearchText + "' wasn't found")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Replace in Files: '%s' with '%s' in %s\n", query.SearchText, query.ReplaceText, options.Root)
+	sb.WriteString("Press Enter to choose which of these changes to apply, or close this tab to cancel.\n\n")
+	for _, change := range changes {
+		sb.WriteString(utility.UnifiedDiff(change.relPath, change.relPath,
+			strings.ReplaceAll(change.original, "\r\n", "\n"), strings.ReplaceAll(change.replaced, "\r\n", "\n")))
+	}
+
+	previewFileBuffer := newSpecialFileBuffer("Replace: "+query.SearchText, sb.String(), "patch")
+	previewFileBuffer.onEnter = func() tview.Primitive {
+		return showApplyReplaceInFilesDialog(previewFileBuffer, changes)
+	}
+	app.SetFocus(previewFileBuffer.editor)
+	statusBar.ShowMessage(fmt.Sprintf("Preview of changes to %d files", len(changes)))
+}
+
+func prepareReplaceInFile(options findinfiles.Options, relPath string, replaceText string,
+	isRegex bool) (*replaceInFilesChange, error) {
+
+	change := &replaceInFilesChange{
+		filename: filepath.Join(options.Root, filepath.FromSlash(relPath)),
+		relPath:  relPath,
+	}
+	change.fileBuffer = findFileBufferByFilename(change.filename)
+	if change.fileBuffer != nil {
+		change.original = string(bufferToBytes(change.fileBuffer.buffer))
+	} else {
+		text, encodingID, err := readFileText(change.filename, "")
+		if err != nil {
+			return nil, err
+		}
+		change.original = text
+		change.encoding = encodingID
+	}
+	change.replaced, change.count = replaceInText(change.original, options.Pattern, replaceText, isRegex)
+	return change, nil
+}
+
+// replaceInText replaces the matches on each line of a text, while keeping
+// the line endings. Regex replacements may refer to groups with `$1`.
+func replaceInText(text string, pattern *regexp.Regexp, replaceText string, isRegex bool) (string, int) {
+	lines := strings.Split(text, "\n")
+	total := 0
+	for i, line := range lines {
+		lineEnding := ""
+		if strings.HasSuffix(line, "\r") {
+			line, lineEnding = line[:len(line)-1], "\r"
+		}
+		var sb strings.Builder
+		count := 0
+		last := 0
+		for _, match := range pattern.FindAllStringSubmatchIndex(line, -1) {
+			if match[0] == match[1] {
+				continue
+			}
+			sb.WriteString(line[last:match[0]])
+			if isRegex {
+				sb.Write(pattern.ExpandString(nil, replaceText, line, match))
+			} else {
+				sb.WriteString(replaceText)
+			}
+			last = match[1]
+			count++
+		}
+		if count != 0 {
+			sb.WriteString(line[last:])
+			lines[i] = sb.String() + lineEnding
+			total += count
+		}
+	}
+	return strings.Join(lines, "\n"), total
+}
+
+func showApplyReplaceInFilesDialog(previewFileBuffer *FileBuffer, changes []*replaceInFilesChange) tview.Primitive {
+	items := []dialog.ListItem{}
+	for i, change := range changes {
+		text := fmt.Sprintf("%s  (%d)", change.relPath, change.count)
+		if change.fileBuffer != nil {
+			text += "  [open]"
+		}
+		items = append(items, dialog.ListItem{Text: tview.Escape(text), Value: strconv.Itoa(i), Checked: true})
+	}
+
+	return ShowListDialog(dialog.ListDialogOptions{
+		Title:      "Replace in Files",
+		Message:    "Apply the replacements to these files:",
+		Buttons:    []string{"Replace", "Cancel"},
+		Width:      70,
+		Height:     18,
+		Items:      items,
+		Checkboxes: true,
+		OnCancel: func() {
+			hideListDialog()
+		},
+		OnAccept: func(value string, index int) {
+			checkedValues := listDialog.CheckedValues()
+			hideListDialog()
+			if index == 1 {
+				return
+			}
+
+			selected := []*replaceInFilesChange{}
+			for _, value := range checkedValues {
+				i, _ := strconv.Atoi(value)
+				selected = append(selected, changes[i])
+			}
+			applyReplaceInFiles(selected)
+			closeFile(previewFileBuffer.uuid)
+		},
+	})
+}
+
+func applyReplaceInFiles(changes []*replaceInFilesChange) {
+	count := 0
+	fileCount := 0
+	problems := []string{}
+	for _, change := range changes {
+		if err := applyReplaceInFile(change); err != nil {
+			problems = append(problems, change.relPath+": "+err.Error())
+			continue
+		}
+		count += change.count
+		fileCount++
+	}
+
+	message := fmt.Sprintf("Replaced %d occurrences in %d files", count, fileCount)
+	if len(problems) != 0 {
+		ShowOkDialog("Replace in Files", message+". These files were skipped:\n"+strings.Join(problems, "\n"), nil)
+		return
+	}
+	statusBar.ShowMessage(message)
+}
+
+// applyReplaceInFile writes a change into its buffer, or to disk if the file
+// isn't open. Files which changed since the preview are left alone.
+func applyReplaceInFile(change *replaceInFilesChange) error {
+	if change.fileBuffer != nil && getFileBufferByID(change.fileBuffer.uuid) != nil {
+		if string(bufferToBytes(change.fileBuffer.buffer)) != change.original {
+			return fmt.Errorf("changed since the preview")
+		}
+		setBufferContents(change.fileBuffer.buffer, change.replaced)
+		return nil
+	}
+
+	text, encodingID, err := readFileText(change.filename, change.encoding)
+	if err != nil {
+		return err
+	}
+	if text != change.original {
+		return fmt.Errorf("changed since the preview")
+	}
+	contents, err := textencoding.Encode([]byte(change.replaced), encodingID)
+	if err != nil {
+		return err
+	}
+	return utility.WriteFile(change.filename, contents)
+}
@@ -0,0 +1,230 @@
+// Package findinfiles searches the files in a directory tree, much like
+// `grep -rn` but skipping whatever .gitignore says to skip.
+package findinfiles
+
+import (
+	"bytes"
+	"context"
+	"dinky/internal/application/textencoding"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const maxFileSize = 16 * 1024 * 1024
+const binaryCheckLength = 8000
+
+type Options struct {
+	Root    string
+	Pattern *regexp.Regexp
+	Include []string // Globs. When given, only files which match one are searched
+	Exclude []string // Globs for files and directories to skip
+
+	// Contents is used in place of what is on disk, e.g. for files with
+	// unsaved changes. The keys are absolute filenames.
+	Contents map[string][]byte
+}
+
+// Match is one hit on a line.
+type Match struct {
+	Line  int // Zero based
+	Start int // Column in runes
+	End   int
+	Text  string // The whole line
+}
+
+// FileMatches holds all of the matches in one file.
+type FileMatches struct {
+	Filename string // Relative to the search root, with slashes
+	Matches  []Match
+}
+
+type searcher struct {
+	options Options
+	include []globMatcher
+	exclude []globMatcher
+}
+
+// Search looks through every file under the root and calls onFile for each
+// file which has matches. Files are searched concurrently but onFile is only
+// called from one goroutine at a time. Search returns when it is finished or
+// the context is cancelled.
+func Search(ctx context.Context, options Options, onFile func(fileMatches FileMatches)) error {
+	s := &searcher{options: options}
+	for _, glob := range options.Include {
+		matcher, err := newGlobMatcher(glob)
+		if err != nil {
+			return err
+		}
+		s.include = append(s.include, matcher)
+	}
+	for _, glob := range options.Exclude {
+		matcher, err := newGlobMatcher(glob)
+		if err != nil {
+			return err
+		}
+		s.exclude = append(s.exclude, matcher)
+	}
+
+	paths := make(chan string, 256)
+	results := make(chan FileMatches, 64)
+
+	var walkErr error
+	go func() {
+		defer close(paths)
+		walkErr = s.walkDir(ctx, "", readGitignore(options.Root, ""), paths)
+	}()
+
+	var workers sync.WaitGroup
+	for range runtime.NumCPU() {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for relPath := range paths {
+				if ctx.Err() != nil {
+					continue // Drain the channel so the walker can finish
+				}
+				if fileMatches, ok := s.searchFile(relPath); ok {
+					results <- fileMatches
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	for fileMatches := range results {
+		if ctx.Err() == nil {
+			onFile(fileMatches)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return walkErr
+}
+
+func (s *searcher) walkDir(ctx context.Context, relDir string, rules []ignoreRule, paths chan<- string) error {
+	entries, err := os.ReadDir(filepath.Join(s.options.Root, filepath.FromSlash(relDir)))
+	if err != nil {
+		if relDir == "" {
+			return err
+		}
+		return nil // Unreadable subdirectories are skipped
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return nil
+		}
+		name := entry.Name()
+		relPath := name
+		if relDir != "" {
+			relPath = relDir + "/" + name
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Follow links to files, but not to directories which could loop.
+			info, err := os.Stat(filepath.Join(s.options.Root, filepath.FromSlash(relPath)))
+			if err != nil || info.IsDir() {
+				continue
+			}
+		} else if !isDir && !entry.Type().IsRegular() {
+			continue
+		}
+
+		if name == ".git" || isIgnored(rules, relPath, isDir) || matchesAny(s.exclude, relPath) {
+			continue
+		}
+
+		if isDir {
+			subRules := append(rules[:len(rules):len(rules)], readGitignore(s.options.Root, relPath)...)
+			if err := s.walkDir(ctx, relPath, subRules, paths); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(s.include) != 0 && !matchesAny(s.include, relPath) {
+			continue
+		}
+		paths <- relPath
+	}
+	return nil
+}
+
+func matchesAny(matchers []globMatcher, relPath string) bool {
+	for _, matcher := range matchers {
+		if matcher.match(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *searcher) searchFile(relPath string) (FileMatches, bool) {
+	filename := filepath.Join(s.options.Root, filepath.FromSlash(relPath))
+	contents, ok := s.options.Contents[filename]
+	if !ok {
+		info, err := os.Stat(filename)
+		if err != nil || info.Size() > maxFileSize {
+			return FileMatches{}, false
+		}
+		contents, err = os.ReadFile(filename)
+		if err != nil {
+			return FileMatches{}, false
+		}
+		// Search the text the way it would be shown when opened.
+		encodingID := textencoding.Detect(contents)
+		if !strings.HasPrefix(encodingID, "utf-16") && isBinary(contents) {
+			return FileMatches{}, false
+		}
+		text, err := textencoding.Decode(contents, encodingID)
+		if err != nil {
+			return FileMatches{}, false
+		}
+		contents = []byte(text)
+	}
+
+	fileMatches := FileMatches{Filename: relPath}
+	lineNumber := 0
+	for len(contents) != 0 {
+		line := contents
+		if i := bytes.IndexByte(contents, '\n'); i != -1 {
+			line = contents[:i]
+			contents = contents[i+1:]
+		} else {
+			contents = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+
+		var text string
+		for _, index := range s.options.Pattern.FindAllIndex(line, -1) {
+			if index[0] == index[1] {
+				continue
+			}
+			if text == "" {
+				text = strings.ToValidUTF8(string(line), "�")
+			}
+			fileMatches.Matches = append(fileMatches.Matches, Match{
+				Line:  lineNumber,
+				Start: utf8.RuneCount(line[:index[0]]),
+				End:   utf8.RuneCount(line[:index[1]]),
+				Text:  text,
+			})
+		}
+		lineNumber++
+	}
+	return fileMatches, len(fileMatches.Matches) != 0
+}
+
+func isBinary(contents []byte) bool {
+	return bytes.IndexByte(contents[:min(len(contents), binaryCheckLength)], 0) != -1
+}
@@ -0,0 +1,75 @@
+package findinfiles
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one line from a .gitignore file.
+type ignoreRule struct {
+	dir     string // Slash separated directory of the .gitignore, relative to the search root
+	matcher globMatcher
+	negate  bool
+	dirOnly bool
+}
+
+// readGitignore loads the rules from the .gitignore in a directory, if any.
+func readGitignore(root string, relDir string) []ignoreRule {
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(relDir), ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{dir: relDir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if line == "" {
+			continue
+		}
+		matcher, err := newGlobMatcher(line)
+		if err != nil {
+			continue
+		}
+		rule.matcher = matcher
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isIgnored applies the rules in order, so later rules win like they do in git.
+func isIgnored(rules []ignoreRule, relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		path := relPath
+		if rule.dir != "" {
+			if !strings.HasPrefix(relPath, rule.dir+"/") {
+				continue
+			}
+			path = relPath[len(rule.dir)+1:]
+		}
+		if rule.matcher.match(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,87 @@
+package findinfiles
+
+import (
+	"regexp"
+	"strings"
+)
+
+// compileGlob turns a glob into a regular expression which matches a whole
+// slash separated path. `*` and `?` stop at slashes, and `**` matches across
+// any number of directories.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// globMatcher matches paths against a glob. Globs without a slash are
+// matched against the last part of the path only, like .gitignore does.
+type globMatcher struct {
+	regex    *regexp.Regexp
+	basename bool
+}
+
+func newGlobMatcher(glob string) (globMatcher, error) {
+	basename := !strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	regex, err := compileGlob(glob)
+	if err != nil {
+		return globMatcher{}, err
+	}
+	return globMatcher{regex: regex, basename: basename}, nil
+}
+
+func (m globMatcher) match(relPath string) bool {
+	if m.basename {
+		relPath = relPath[strings.LastIndexByte(relPath, '/')+1:]
+	}
+	return m.regex.MatchString(relPath)
+}
+
+// ParseGlobs splits a comma separated list of globs.
+func ParseGlobs(globs string) []string {
+	result := []string{}
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob != "" {
+			result = append(result, glob)
+		}
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package application
+
+import (
+	"dinky/internal/tui/dialog"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen/micro/buffer"
+)
+
+const maxFindInOpenFilesResults = 1000
+const findResultSnippetLength = 60
+
+type findResult struct {
+	fileBuffer *FileBuffer
+	start      buffer.Loc
+	end        buffer.Loc
+}
+
+// searchRegexp builds the regular expression used to search with the given
+// options.
+func searchRegexp(searchText string, isRegex bool, caseSensitive bool) (*regexp.Regexp, error) {
+	if !isRegex {
+		searchText = regexp.QuoteMeta(searchText)
+	}
+	if !caseSensitive {
+		searchText = "(?i)" + searchText
+	}
+	return regexp.Compile(searchText)
+}
+
+// findInFileBuffer returns the matches in a buffer, up to a limit.
+func findInFileBuffer(fileBuffer *FileBuffer, re *regexp.Regexp, limit int) []findResult {
+	results := []findResult{}
+	buf := fileBuffer.buffer
+	for y := 0; y < buf.LinesNum() && len(results) < limit; y++ {
+		line := buf.LineBytes(y)
+		for _, match := range re.FindAllIndex(line, limit-len(results)) {
+			if match[0] == match[1] {
+				continue // Empty matches aren't much use to jump to
+			}
+			results = append(results, findResult{
+				fileBuffer: fileBuffer,
+				start:      buffer.Loc{X: utf8.RuneCount(line[:match[0]]), Y: y},
+				end:        buffer.Loc{X: utf8.RuneCount(line[:match[1]]), Y: y},
+			})
+		}
+	}
+	return results
+}
+
+func findResultSnippet(result findResult) string {
+	line := string(result.fileBuffer.buffer.LineBytes(result.start.Y))
+	line = strings.ReplaceAll(line, "\t", " ")
+	runes := []rune(line)
+
+	// Keep the match in view on long lines.
+	startX := 0
+	if result.start.X > findResultSnippetLength/2 {
+		startX = result.start.X - findResultSnippetLength/4
+		runes = runes[startX:]
+	}
+	snippet := strings.TrimSpace(string(runes[:min(len(runes), findResultSnippetLength)]))
+	if startX != 0 {
+		snippet = "…" + snippet
+	}
+	if len(runes) > findResultSnippetLength {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func handleFindInOpenFiles() tview.Primitive {
+	currentFindbar := currentFileBuffer.findbar
+	searchText := currentFindbar.SearchStringField.GetText()
+	if searchText == "" {
+		currentFileBuffer.openFindbar()
+		statusBar.ShowWarning("Enter some text to find first")
+		return currentFindbar
+	}
+
+	re, err := currentFindbar.SearchRegexp()
+	if err != nil {
+		statusBar.ShowError(err.Error())
+		return nil
+	}
+
+	results := []findResult{}
+	for _, fileBuffer := range fileBuffers {
+		results = append(results, findInFileBuffer(fileBuffer, re, maxFindInOpenFilesResults-len(results))...)
+	}
+	if len(results) == 0 {
+		statusBar.ShowWarning("'" + searchText + "' wasn't found in any open file")
+		return nil
+	}
+	return showFindResultsDialog(searchText, results)
+}
+
+func showFindResultsDialog(searchText string, results []findResult) tview.Primitive {
+	items := []dialog.ListItem{}
+	for i, result := range results {
+		name := tabBarLine.TabTitle(result.fileBuffer.uuid)
+		items = append(items, dialog.ListItem{
+			Text:  tview.Escape(fmt.Sprintf("%s:%d: %s", name, result.start.Y+1, findResultSnippet(result))),
+			Value: strconv.Itoa(i),
+		})
+	}
+
+	message := fmt.Sprintf("%d matches for '%s':", len(results), searchText)
+	if len(results) >= maxFindInOpenFilesResults {
+		message = fmt.Sprintf("First %d matches for '%s':", len(results), searchText)
+	}
+
+	return ShowListDialog(dialog.ListDialogOptions{
+		Title:   "Find in Open Files",
+		Message: tview.Escape(message),
+		Buttons: []string{"Go To", "Cancel"},
+		Width:   80,
+		Height:  20,
+		Items:   items,
+		OnCancel: func() {
+			hideListDialog()
+		},
+		OnAccept: func(value string, index int) {
+			hideListDialog()
+			if index == 1 {
+				return
+			}
+			i, _ := strconv.Atoi(value)
+			goToFindResult(results[i])
+		},
+	})
+}
+
+func goToFindResult(result findResult) {
+	fileBuffer := result.fileBuffer
+	if getFileBufferByID(fileBuffer.uuid) == nil {
+		return // Closed in the meantime
+	}
+	selectTab(fileBuffer.uuid)
+
+	// The buffer may have been edited since the search was made.
+	start := clampLoc(fileBuffer.buffer, result.start)
+	end := clampLoc(fileBuffer.buffer, result.end)
+	cursor := fileBuffer.editor.Cursor()
+	cursor.GotoLoc(end)
+	cursor.SetSelectionStart(start)
+	cursor.SetSelectionEnd(end)
+	fileBuffer.editor.Relocate()
+	app.SetFocus(fileBuffer.editor)
+}
+
+// clampLoc moves a location inside the buffer, also on the line itself.
+func clampLoc(buf *buffer.Buffer, loc buffer.Loc) buffer.Loc {
+	loc = loc.Clamp(buf.Start(), buf.End())
+	loc.X = min(max(loc.X, 0), utf8.RuneCount(buf.LineBytes(loc.Y)))
+	return loc
+}
@@ -0,0 +1,582 @@
+package application
+
+import (
+	"bytes"
+	"dinky/internal/application/selections"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/util"
+	"gopkg.in/yaml.v3"
+)
+
+// dataError is a problem found while parsing structured data, and where it
+// is in the text.
+type dataError struct {
+	line    int // Starting at 1, or 0 if it isn't known
+	column  int // In bytes, starting at 1, or 0 if it isn't known
+	message string
+}
+
+func (e *dataError) Error() string {
+	return e.message
+}
+
+func handleFormatJSON() tview.Primitive {
+	formatData("JSON", formatJSON)
+	return nil
+}
+
+func handleFormatJSONSortedKeys() tview.Primitive {
+	formatData("JSON", formatJSONSortedKeys)
+	return nil
+}
+
+func handleMinifyJSON() tview.Primitive {
+	formatData("JSON", func(text string, indent string) (string, error) {
+		var minified bytes.Buffer
+		if err := json.Compact(&minified, []byte(text)); err != nil {
+			return "", jsonError(text, err)
+		}
+		return strings.TrimSpace(minified.String()), nil
+	})
+	return nil
+}
+
+func handleFormatXML() tview.Primitive {
+	formatData("XML", formatXML)
+	return nil
+}
+
+func handleFormatYAML() tview.Primitive {
+	formatData("YAML", formatYAML)
+	return nil
+}
+
+func handleFormatTOML() tview.Primitive {
+	formatData("TOML", formatTOML)
+	return nil
+}
+
+// formatData replaces the text selected by each cursor, or the whole file if
+// nothing is selected, with the result of the format function. If the text
+// can't be parsed, the cursor is put where the problem is.
+func formatData(formatName string, format func(text string, indent string) (string, error)) {
+	indent := indentUnit()
+	formatText := func(text string) (string, error) {
+		formatted, err := format(text, indent)
+		if err != nil {
+			return "", err
+		}
+		// Keep the line ending at the end, if there was one.
+		formatted = strings.TrimRight(formatted, "\n")
+		if strings.HasSuffix(text, "\n") {
+			formatted += "\n"
+		}
+		return formatted, nil
+	}
+
+	editor := currentFileBuffer.editor
+	if selections.HasSelection(editor) {
+		formattedAny := false
+		err := selections.Replace(editor, func(text string) (string, error) {
+			if strings.TrimSpace(text) == "" {
+				return text, nil
+			}
+			formattedAny = true
+			return formatText(text)
+		})
+		var selectionErr *selections.Error
+		if errors.As(err, &selectionErr) {
+			showDataError(formatName, selectionErr.Text, selectionErr.Start, selectionErr.Err)
+		} else if !formattedAny {
+			statusBar.ShowWarning("There is no " + formatName + " to format")
+		}
+		return
+	}
+
+	buf := currentFileBuffer.buffer
+	// ApplyDiff works on the text with `\n` line endings.
+	text := strings.ReplaceAll(string(buf.Bytes()), "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		statusBar.ShowWarning("There is no " + formatName + " to format")
+		return
+	}
+	formatted, err := formatText(text)
+	if err != nil {
+		showDataError(formatName, text, buf.Start(), err)
+		return
+	}
+	if formatted != text {
+		isDOS := buf.Settings["fileformat"] == "dos"
+		buf.DoSetOptionNative("fileformat", "unix")
+		buf.ApplyDiff(formatted)
+		if isDOS {
+			buf.DoSetOptionNative("fileformat", "dos")
+		}
+		editor.Relocate()
+	}
+}
+
+// dataText returns the selected text, or the whole file if nothing is
+// selected, and where it starts.
+func dataText() (text string, start buffer.Loc) {
+	buf := currentFileBuffer.buffer
+	cursor := currentFileBuffer.editor.Cursor()
+	start, end := buf.Start(), buf.End()
+	if cursor.HasSelection() {
+		start, end = cursor.CurSelection[0], cursor.CurSelection[1]
+		if start.GreaterThan(end) {
+			start, end = end, start
+		}
+	}
+	return string(buf.Substr(start, end)), start
+}
+
+// indentUnit is one level of indentation in the current file.
+func indentUnit() string {
+	if currentFileBuffer.buffer.Settings["tabstospaces"].(bool) {
+		return strings.Repeat(" ", int(currentFileBuffer.buffer.Settings["tabsize"].(float64)))
+	}
+	return "\t"
+}
+
+// showDataError moves the cursor to where a parse error is and shows it.
+// The text started at `start` in the buffer.
+func showDataError(formatName string, text string, start buffer.Loc, err error) {
+	var problem *dataError
+	if !errors.As(err, &problem) || problem.line == 0 {
+		statusBar.ShowWarning("Invalid " + formatName + ": " + err.Error())
+		return
+	}
+
+	lines := strings.Split(text, "\n")
+	lineIndex := min(problem.line, len(lines)) - 1
+	loc := buffer.Loc{X: 0, Y: start.Y + lineIndex}
+	if problem.column > 0 {
+		line := lines[lineIndex]
+		loc.X = util.CharacterCountInString(line[:min(problem.column-1, len(line))])
+	}
+	if lineIndex == 0 {
+		loc.X += start.X
+	}
+	currentFileBuffer.editor.GoToLoc(loc)
+
+	position := fmt.Sprintf("line %d", loc.Y+1)
+	if problem.column > 0 {
+		position += fmt.Sprintf(", column %d", loc.X+1)
+	}
+	statusBar.ShowWarning("Invalid " + formatName + " at " + position + ": " + problem.message)
+}
+
+// offsetPosition finds the line and column of a byte offset in some text.
+func offsetPosition(text string, offset int) (line int, column int) {
+	offset = max(0, min(offset, len(text)))
+	line = strings.Count(text[:offset], "\n") + 1
+	column = offset - strings.LastIndex(text[:offset], "\n")
+	return line, column
+}
+
+// formatJSON indents JSON and keeps the keys in the order they are in.
+func formatJSON(text string, indent string) (string, error) {
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, []byte(text), "", indent); err != nil {
+		return "", jsonError(text, err)
+	}
+	return strings.TrimSpace(formatted.String()), nil
+}
+
+func formatJSONSortedKeys(text string, indent string) (string, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(text)); err != nil {
+		return "", jsonError(text, err)
+	}
+
+	// Numbers are kept as they were written instead of becoming floats.
+	decoder := json.NewDecoder(&compact)
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	// Maps are written with their keys sorted.
+	var formatted strings.Builder
+	encoder := json.NewEncoder(&formatted)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return formatted.String(), nil
+}
+
+func jsonError(text string, err error) error {
+	var syntaxError *json.SyntaxError
+	if !errors.As(err, &syntaxError) {
+		return err
+	}
+	// The offset is just after the character which was wrong.
+	line, column := offsetPosition(text, int(syntaxError.Offset)-1)
+	return &dataError{line: line, column: column, message: syntaxError.Error()}
+}
+
+// formatXML puts each element on its own line, indented by how deeply it is
+// nested. An element holding only text stays on one line.
+func formatXML(text string, indent string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	// The text is in the editor, so it is UTF-8 whatever encoding the
+	// declaration names.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	xmlError := func(message string) error {
+		line, column := decoder.InputPos()
+		return &dataError{line: line, column: column, message: message}
+	}
+
+	// RawToken keeps namespace prefixes as they are written, but doesn't
+	// check that elements are closed properly.
+	tokens := []xml.Token{}
+	open := []xml.Name{}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var syntaxError *xml.SyntaxError
+			if errors.As(err, &syntaxError) {
+				return "", xmlError(syntaxError.Msg)
+			}
+			return "", xmlError(err.Error())
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+		case xml.EndElement:
+			if len(open) == 0 {
+				return "", xmlError("</" + xmlName(t.Name) + "> doesn't close anything")
+			}
+			if open[len(open)-1] != t.Name {
+				return "", xmlError("<" + xmlName(open[len(open)-1]) + "> is closed by </" + xmlName(t.Name) + ">")
+			}
+			open = open[:len(open)-1]
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+	if len(open) != 0 {
+		return "", xmlError("<" + xmlName(open[len(open)-1]) + "> isn't closed")
+	}
+
+	var formatted strings.Builder
+	depth := 0
+	writeLine := func(line string) {
+		formatted.WriteString(strings.Repeat(indent, depth) + line + "\n")
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i].(type) {
+		case xml.StartElement:
+			startTag := "<" + xmlName(t.Name)
+			for _, attr := range t.Attr {
+				startTag += " " + xmlName(attr.Name) + `="` + xmlEscape(attr.Value, true) + `"`
+			}
+
+			// Look for the end tag after no more than some text.
+			j := i + 1
+			content := ""
+			if charData, ok := tokens[j].(xml.CharData); ok {
+				content = string(charData)
+				j++
+			}
+			if _, ok := tokens[j].(xml.EndElement); ok {
+				if strings.TrimSpace(content) == "" {
+					writeLine(startTag + "/>")
+				} else {
+					writeLine(startTag + ">" + xmlEscape(content, false) + "</" + xmlName(t.Name) + ">")
+				}
+				i = j
+				continue
+			}
+			writeLine(startTag + ">")
+			depth++
+		case xml.EndElement:
+			depth--
+			writeLine("</" + xmlName(t.Name) + ">")
+		case xml.CharData:
+			if content := strings.TrimSpace(string(t)); content != "" {
+				writeLine(xmlEscape(content, false))
+			}
+		case xml.Comment:
+			writeLine("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			if len(t.Inst) == 0 {
+				writeLine("<?" + t.Target + "?>")
+			} else {
+				writeLine("<?" + t.Target + " " + string(t.Inst) + "?>")
+			}
+		case xml.Directive:
+			writeLine("<!" + string(t) + ">")
+		}
+	}
+	return formatted.String(), nil
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\n", "&#xA;",
+	"\r", "&#xD;", "\t", "&#x9;")
+
+func xmlEscape(s string, attr bool) string {
+	if attr {
+		return xmlAttrEscaper.Replace(s)
+	}
+	return xmlTextEscaper.Replace(s)
+}
+
+// yamlErrorPattern matches the line number in errors from the YAML parser.
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlIndentWidth is how many spaces to indent YAML by. It can't be
+// indented with tabs.
+func yamlIndentWidth(indent string) int {
+	if indent == "\t" {
+		return 2
+	}
+	return len(indent)
+}
+
+func yamlError(err error) error {
+	if match := yamlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return &dataError{line: line, message: match[2]}
+	}
+	return errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+}
+
+// formatYAML re-indents YAML. The order of keys and comments are kept.
+func formatYAML(text string, indent string) (string, error) {
+	width := yamlIndentWidth(indent)
+
+	documents := []string{}
+	decoder := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", yamlError(err)
+		}
+
+		var formatted strings.Builder
+		encoder := yaml.NewEncoder(&formatted)
+		encoder.SetIndent(width)
+		if err := encoder.Encode(&document); err != nil {
+			return "", err
+		}
+		if err := encoder.Close(); err != nil {
+			return "", err
+		}
+		documents = append(documents, formatted.String())
+	}
+	if len(documents) == 0 {
+		// Only comments, which have nothing to hang from.
+		return text, nil
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+// formatTOML lays out TOML with one space around each `=` and a blank line
+// before each table. Values, comments and the order of everything are kept.
+func formatTOML(text string, indent string) (string, error) {
+	// Decoding finds problems, like a key given twice, which the parser
+	// below doesn't.
+	var document map[string]any
+	if err := toml.Unmarshal([]byte(text), &document); err != nil {
+		var decodeError *toml.DecodeError
+		if errors.As(err, &decodeError) {
+			line, column := decodeError.Position()
+			return "", &dataError{line: line, column: column, message: strings.TrimPrefix(err.Error(), "toml: ")}
+		}
+		return "", errors.New(strings.TrimPrefix(err.Error(), "toml: "))
+	}
+
+	data := []byte(text)
+	parser := unstable.Parser{KeepComments: true}
+	parser.Reset(data)
+	var formatted strings.Builder
+	previousKind := unstable.Invalid
+	for parser.NextExpression() {
+		expression := parser.Expression()
+		isTable := expression.Kind == unstable.Table || expression.Kind == unstable.ArrayTable
+		if previousKind != unstable.Invalid && ((isTable && previousKind != unstable.Comment) ||
+			followsBlankLine(data, tomlExpressionOffset(&parser, expression))) {
+			formatted.WriteString("\n")
+		}
+		previousKind = expression.Kind
+
+		switch expression.Kind {
+		case unstable.Comment:
+			formatted.Write(bytes.TrimRight(expression.Data, "\r"))
+		case unstable.Table:
+			formatted.WriteString("[" + tomlKey(&parser, expression) + "]")
+		case unstable.ArrayTable:
+			formatted.WriteString("[[" + tomlKey(&parser, expression) + "]]")
+		case unstable.KeyValue:
+			formatted.WriteString(tomlKeyValue(&parser, expression, indent, 0))
+		}
+		if comment := expression.Next(); comment != nil {
+			formatted.WriteString(" " + strings.TrimRight(string(comment.Data), "\r"))
+		}
+		formatted.WriteString("\n")
+	}
+	if err := parser.Error(); err != nil {
+		return "", err
+	}
+	return formatted.String(), nil
+}
+
+// tomlExpressionOffset finds where a top level expression starts, near
+// enough to know which line it is on.
+func tomlExpressionOffset(parser *unstable.Parser, expression *unstable.Node) int {
+	if expression.Kind == unstable.Comment {
+		return int(expression.Raw.Offset)
+	}
+	keys := expression.Key()
+	keys.Next()
+	return int(keys.Node().Raw.Offset)
+}
+
+// followsBlankLine checks if the line before the one at offset is empty.
+func followsBlankLine(data []byte, offset int) bool {
+	lineStart := bytes.LastIndexByte(data[:offset], '\n')
+	if lineStart == -1 {
+		return false
+	}
+	previousLineStart := bytes.LastIndexByte(data[:lineStart], '\n') + 1
+	return len(bytes.TrimSpace(data[previousLineStart:lineStart])) == 0
+}
+
+// tomlKey writes a dotted key as it was written, without the spaces.
+func tomlKey(parser *unstable.Parser, node *unstable.Node) string {
+	parts := []string{}
+	keys := node.Key()
+	for keys.Next() {
+		parts = append(parts, string(parser.Raw(keys.Node().Raw)))
+	}
+	return strings.Join(parts, ".")
+}
+
+func tomlKeyValue(parser *unstable.Parser, node *unstable.Node, indent string, depth int) string {
+	return tomlKey(parser, node) + " = " + tomlValue(parser, node.Value(), indent, depth)
+}
+
+// The widest an array can be and still be put on one line.
+const tomlArrayWidth = 80
+
+func tomlValue(parser *unstable.Parser, node *unstable.Node, indent string, depth int) string {
+	switch node.Kind {
+	case unstable.String:
+		return string(parser.Raw(node.Raw))
+	case unstable.InlineTable:
+		keyValues := []string{}
+		children := node.Children()
+		for children.Next() {
+			keyValues = append(keyValues, tomlKeyValue(parser, children.Node(), indent, depth))
+		}
+		if len(keyValues) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(keyValues, ", ") + " }"
+	case unstable.Array:
+		return tomlArray(parser, node, indent, depth)
+	default:
+		return string(node.Data)
+	}
+}
+
+// tomlArray puts an array on one line, unless it is too long or has
+// comments in it. Then each value gets its own line.
+func tomlArray(parser *unstable.Parser, node *unstable.Node, indent string, depth int) string {
+	type arrayLine struct {
+		value   string
+		comment string
+	}
+	lines := []arrayLine{}
+	values := []string{}
+	hasComments := false
+	children := node.Children()
+	for children.Next() {
+		child := children.Node()
+		if child.Kind != unstable.Comment {
+			value := tomlValue(parser, child, indent, depth+1)
+			values = append(values, value)
+			lines = append(lines, arrayLine{value: value})
+			continue
+		}
+
+		// A comment and the ones on the lines after it.
+		hasComments = true
+		comments := []*unstable.Node{child}
+		more := child.Children()
+		for more.Next() {
+			comments = append(comments, more.Node())
+		}
+		for _, comment := range comments {
+			commentText := strings.TrimRight(string(comment.Data), "\r")
+			if len(lines) != 0 && lines[len(lines)-1].value != "" && lines[len(lines)-1].comment == "" &&
+				!startsLine(parser.Data(), int(comment.Raw.Offset)) {
+
+				lines[len(lines)-1].comment = commentText
+			} else {
+				lines = append(lines, arrayLine{comment: commentText})
+			}
+		}
+	}
+
+	oneLine := "[" + strings.Join(values, ", ") + "]"
+	if !hasComments && len(oneLine) <= tomlArrayWidth && !strings.Contains(oneLine, "\n") {
+		return oneLine
+	}
+
+	var array strings.Builder
+	array.WriteString("[\n")
+	for _, line := range lines {
+		array.WriteString(strings.Repeat(indent, depth+1))
+		if line.value != "" {
+			array.WriteString(line.value + ",")
+			if line.comment != "" {
+				array.WriteString(" ")
+			}
+		}
+		array.WriteString(line.comment + "\n")
+	}
+	array.WriteString(strings.Repeat(indent, depth) + "]")
+	return array.String()
+}
+
+// startsLine checks if only white space comes before offset on its line.
+func startsLine(data []byte, offset int) bool {
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return len(bytes.TrimSpace(data[lineStart:offset])) == 0
+}
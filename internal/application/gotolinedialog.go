@@ -1,7 +1,9 @@
 package application
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/rivo/tview"
 	"github.com/sedwards2009/smidgen/micro/buffer"
@@ -26,56 +28,54 @@ func parseAndGoToLine(input string) {
 		return
 	}
 
-	var lineNum, colNum int
-	var err error
+	lineNum, colNum, err := parseLineColumn(input)
+	if err != nil {
+		statusBar.ShowError(err.Error())
+		return
+	}
 
-	// Check if input contains a colon (line:column format)
-	colonIndex := -1
-	for i, r := range input {
-		if r == ':' {
-			colonIndex = i
-			break
-		}
+	if err := goToLineColumn(currentFileBuffer, lineNum, colNum); err != nil {
+		statusBar.ShowError(err.Error())
+		return
 	}
 
-	if colonIndex != -1 {
-		// Parse line:column format
-		lineStr := input[:colonIndex]
-		colStr := input[colonIndex+1:]
+	statusBar.ShowMessage("Jumped to line " + strconv.Itoa(lineNum))
+}
 
-		lineNum, err = strconv.Atoi(lineStr)
-		if err != nil {
-			statusBar.ShowError("Invalid line number")
-			return
-		}
+// parseLineColumn parses "line" or "line:column" into 1-based numbers. The
+// column defaults to 1.
+func parseLineColumn(input string) (lineNum int, colNum int, err error) {
+	lineStr, colStr, hasColumn := strings.Cut(input, ":")
 
+	lineNum, err = strconv.Atoi(lineStr)
+	if err != nil {
+		return 0, 0, errors.New("Invalid line number")
+	}
+
+	colNum = 1
+	if hasColumn {
 		colNum, err = strconv.Atoi(colStr)
 		if err != nil {
-			statusBar.ShowError("Invalid column number")
-			return
-		}
-	} else {
-		// Parse line number only
-		lineNum, err = strconv.Atoi(input)
-		if err != nil {
-			statusBar.ShowError("Invalid line number")
-			return
+			return 0, 0, errors.New("Invalid column number")
 		}
-		colNum = 1
 	}
+	return lineNum, colNum, nil
+}
 
+// goToLineColumn moves the cursor to a 1-based line and column. The column
+// is clamped to the length of the line.
+func goToLineColumn(fileBuffer *FileBuffer, lineNum int, colNum int) error {
 	// Convert to 0-based indexing
 	lineNum--
 	colNum--
 
 	// Validate line number
-	if lineNum < 0 || lineNum >= currentFileBuffer.buffer.LinesNum() {
-		statusBar.ShowError("Line number out of range")
-		return
+	if lineNum < 0 || lineNum >= fileBuffer.buffer.LinesNum() {
+		return errors.New("Line number out of range")
 	}
 
 	// Validate column number
-	lineLength := len([]rune(currentFileBuffer.buffer.Line(lineNum)))
+	lineLength := len([]rune(fileBuffer.buffer.Line(lineNum)))
 	if colNum < 0 {
 		colNum = 0
 	} else if colNum > lineLength {
@@ -83,7 +83,6 @@ func parseAndGoToLine(input string) {
 	}
 
 	// Move cursor to the specified location
-	currentFileBuffer.editor.GoToLoc(buffer.Loc{X: colNum, Y: lineNum})
-
-	statusBar.ShowMessage("Jumped to line " + strconv.Itoa(lineNum+1))
+	fileBuffer.editor.GoToLoc(buffer.Loc{X: colNum, Y: lineNum})
+	return nil
 }
@@ -0,0 +1,119 @@
+package application
+
+import (
+	"dinky/internal/application/filtercommandaction"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/renameio/v2"
+)
+
+// history is the on-disk format of the recently used search text,
+// replacements, shell commands, directories and command palette entries.
+// Each list is most recent first.
+type history struct {
+	FindbarSearches         []string `json:"findbarSearches,omitempty"`
+	FindbarReplacements     []string `json:"findbarReplacements,omitempty"`
+	FindInFilesSearches     []string `json:"findInFilesSearches,omitempty"`
+	FindInFilesReplacements []string `json:"findInFilesReplacements,omitempty"`
+	FindInFilesDirectories  []string `json:"findInFilesDirectories,omitempty"`
+	FilterCommands          []string `json:"filterCommands,omitempty"`
+	FilterDirectories       []string `json:"filterDirectories,omitempty"`
+	Commands                []string `json:"commands,omitempty"`
+}
+
+var recentFilterCommands []string
+var recentFilterDirectories []string
+
+func historyFilePath() string {
+	settingsDir := userSettingsDirPath()
+	if settingsDir == "" {
+		return ""
+	}
+	return filepath.Join(settingsDir, "history.json")
+}
+
+// loadHistory reads the histories saved by the last session.
+func loadHistory() {
+	filtercommandaction.OnHistoryChange = func(commands []string, directories []string) {
+		recentFilterCommands = commands
+		recentFilterDirectories = directories
+		saveHistory()
+	}
+
+	var h history
+	if historyPath := historyFilePath(); historyPath != "" {
+		if data, err := os.ReadFile(historyPath); err == nil {
+			if err := json.Unmarshal(data, &h); err != nil {
+				log.Printf("Ignoring unreadable history file: %v", err)
+			}
+		}
+	}
+
+	findbarRecentSearchTextHistory = capHistory(h.FindbarSearches)
+	findbarRecentReplaceTextHistory = capHistory(h.FindbarReplacements)
+	recentFindInFilesSearches = capHistory(h.FindInFilesSearches)
+	recentFindInFilesReplacements = capHistory(h.FindInFilesReplacements)
+	recentFindInFilesDirectories = capHistory(h.FindInFilesDirectories)
+	recentFilterCommands = capHistory(h.FilterCommands)
+	recentFilterDirectories = capHistory(h.FilterDirectories)
+	recentCommands = capHistory(h.Commands)
+	filtercommandaction.SetHistory(recentFilterCommands, recentFilterDirectories, settings.HistorySize)
+}
+
+// applyHistorySize trims the histories and sets the size of the open
+// findbars and the filter dialog after the history size setting changes.
+func applyHistorySize() {
+	findbarRecentSearchTextHistory = capHistory(findbarRecentSearchTextHistory)
+	findbarRecentReplaceTextHistory = capHistory(findbarRecentReplaceTextHistory)
+	recentFindInFilesSearches = capHistory(recentFindInFilesSearches)
+	recentFindInFilesReplacements = capHistory(recentFindInFilesReplacements)
+	recentFindInFilesDirectories = capHistory(recentFindInFilesDirectories)
+	recentFilterCommands = capHistory(recentFilterCommands)
+	recentFilterDirectories = capHistory(recentFilterDirectories)
+	recentCommands = capHistory(recentCommands)
+	filtercommandaction.SetHistory(recentFilterCommands, recentFilterDirectories, settings.HistorySize)
+	for _, fileBuffer := range fileBuffers {
+		fileBuffer.findbar.MaxHistorySize = settings.HistorySize
+		fileBuffer.findbar.SetSearchTextHistory(findbarRecentSearchTextHistory)
+		fileBuffer.findbar.SetReplaceTextHistory(findbarRecentReplaceTextHistory)
+	}
+	saveHistory()
+}
+
+func capHistory(entries []string) []string {
+	return entries[:min(len(entries), settings.HistorySize)]
+}
+
+// saveHistory writes the histories out. It is called whenever one changes.
+func saveHistory() {
+	historyPath := historyFilePath()
+	if historyPath == "" {
+		return
+	}
+
+	h := history{
+		FindbarSearches:         findbarRecentSearchTextHistory,
+		FindbarReplacements:     findbarRecentReplaceTextHistory,
+		FindInFilesSearches:     recentFindInFilesSearches,
+		FindInFilesReplacements: recentFindInFilesReplacements,
+		FindInFilesDirectories:  recentFindInFilesDirectories,
+		FilterCommands:          recentFilterCommands,
+		FilterDirectories:       recentFilterDirectories,
+		Commands:                recentCommands,
+	}
+	if err := os.MkdirAll(filepath.Dir(historyPath), os.ModePerm); err != nil {
+		log.Printf("Failed to create settings directory: %v", err)
+		return
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		log.Printf("Failed to encode history: %v", err)
+		return
+	}
+	if err := renameio.WriteFile(historyPath, data, 0600); err != nil {
+		log.Printf("Failed to write history file: %v", err)
+	}
+}
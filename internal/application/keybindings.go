@@ -2,6 +2,9 @@ package application
 
 import (
 	"log"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/sedwards2009/smidgen"
@@ -22,7 +25,9 @@ type KeyDesc struct {
 var dinkyKeyBindings map[smidgen.KeyDesc]string
 var dinkyKeyToActionMapping map[string]string
 
-func initKeyBindings() {
+// setDefaultKeyBindings fills the key to action mappings with the built in
+// bindings.
+func setDefaultKeyBindings() {
 	smidgenSingleLineKeyToActionMapping = map[string]string{
 		"Right": smidgen.ActionCursorRight,
 		"Left":  smidgen.ActionCursorLeft,
@@ -135,6 +140,8 @@ func initKeyBindings() {
 		"Alt-s":      ACTION_OPEN_SELECTION_MENU,
 		"Alt-g":      ACTION_OPEN_GO_MENU,
 		"Alt-t":      ACTION_OPEN_TRANSFORM_MENU,
+		"Alt-n":      ACTION_OPEN_ENCODE_MENU,
+		"Alt-d":      ACTION_OPEN_DATA_MENU,
 		"Alt-v":      ACTION_OPEN_VIEW_MENU,
 		"Alt-h":      ACTION_OPEN_HELP_MENU,
 		"Ctrl-q":     ACTION_QUIT,
@@ -146,32 +153,69 @@ func initKeyBindings() {
 		"Ctrl-r":     ACTION_FIND_AND_REPLACE,
 		"Alt-PgDown": ACTION_NEXT_BOOKMARK,
 		"Alt-PgUp":   ACTION_PREVIOUS_BOOKMARK,
+		"F1":         ACTION_COMMAND_PALETTE,
 	}
+}
+
+// initKeyBindings builds the key bindings from the defaults and the keymap
+// preset, with the user's keybindings.json on top. It returns the problems
+// found in the user's bindings.
+func initKeyBindings() []string {
+	setDefaultKeyBindings()
+	problems := applyKeymap(settings.Keymap)
+	defaultKeyActions = keyActions()
+	problems = append(problems, applyUserKeyBindings(userKeyBindings)...)
 
 	actionToKeyMapping = make(map[string]string)
-	for key, action := range smidgenKeyToActionMapping {
-		if action == "" {
-			continue
+	// Some actions have more than one key. Menus show the user's own key if
+	// there is one, then the keymap's, otherwise the first in sorted order so
+	// that it is the same one each time. Dinky's bindings take precedence like
+	// they do in editorInputCapture.
+	for _, mapping := range []map[string]string{userKeyBindings, keymapBindings[settings.Keymap],
+		dinkyKeyToActionMapping, smidgenKeyToActionMapping} {
+
+		for _, key := range slices.Sorted(maps.Keys(mapping)) {
+			action := mapping[key]
+			if _, ok := actionToKeyMapping[action]; ok || action == "" || !isKeyBoundTo(key, action) ||
+				!isReachableKey(key) {
+
+				continue
+			}
+			actionToKeyMapping[action] = key
 		}
-		actionToKeyMapping[action] = key
 	}
 
-	smidgenDefaultKeyBindings = smidgen.ParseKeybindings(smidgenKeyToActionMapping)
-	smidgenSingleLineKeyBindings = smidgen.ParseKeybindings(smidgenSingleLineKeyToActionMapping)
-
-	for key, action := range dinkyKeyToActionMapping {
-		if action == "" {
-			continue
+	smidgenDefaultKeyBindings = smidgen.ParseKeybindings(withoutChords(smidgenKeyToActionMapping))
+	smidgenSingleLineKeyBindings = smidgen.ParseKeybindings(withoutChords(smidgenSingleLineKeyToActionMapping))
+
+	// Chords are handled in editorInputCapture, whichever actions they run.
+	keyChords = nil
+	for _, mapping := range []map[string]string{dinkyKeyToActionMapping, smidgenKeyToActionMapping} {
+		for _, key := range slices.Sorted(maps.Keys(mapping)) {
+			if !isChord(key) {
+				continue
+			}
+			if keys, ok := parseKeyChord(key); ok {
+				keyChords = append(keyChords, keyChord{keys: keys, names: strings.Fields(key), action: mapping[key]})
+			} else {
+				log.Printf("Failed to parse key chord: %s", key)
+			}
 		}
-		actionToKeyMapping[action] = key
 	}
 
 	dinkyKeyBindings = make(map[smidgen.KeyDesc]string)
-	for key, action := range dinkyKeyToActionMapping {
+	for key, action := range withoutChords(dinkyKeyToActionMapping) {
 		if desc, ok := smidgen.ParseKeySequence(key); ok {
 			dinkyKeyBindings[desc] = action
 		} else {
 			log.Printf("Failed to parse key sequence: %s", key)
 		}
 	}
+	return problems
+}
+
+// isKeyBoundTo checks that a key from one of the mappings ended up bound to
+// the action.
+func isKeyBoundTo(key string, action string) bool {
+	return dinkyKeyToActionMapping[key] == action || smidgenKeyToActionMapping[key] == action
 }
@@ -0,0 +1,170 @@
+package application
+
+import (
+	"dinky/internal/tui/keybindingsdialog"
+	"dinky/internal/tui/style"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const keyBindingsDialogName = "keyBindingsDialog"
+
+var keyBindingsDialog *keybindingsdialog.KeyBindingsDialog
+
+func handleKeyBindings() tview.Primitive {
+	if keyBindingsDialog == nil {
+		keyBindingsDialog = keybindingsdialog.NewKeyBindingsDialog(app)
+		style.StyleKeyBindingsDialog(keyBindingsDialog)
+	}
+
+	modalPages.AddPage(keyBindingsDialogName, keyBindingsDialog, true, true)
+	keyBindingsDialog.Open(keybindingsdialog.KeyBindingsDialogOptions{
+		Items: keyBindingItems(),
+		OnCancel: func() {
+			keyBindingsDialog.Close()
+			modalPages.RemovePage(keyBindingsDialogName)
+			app.SetFocus(currentFileBuffer.editor)
+		},
+		OnKey:    bindRecordedKey,
+		OnRemove: removeActionKeys,
+		OnReset:  resetActionKeys,
+	})
+	style.StyleKeyBindingsDialog(keyBindingsDialog)
+	return keyBindingsDialog
+}
+
+// keyBindingItems lists the menu commands followed by every other action
+// which has a key.
+func keyBindingItems() []keybindingsdialog.Item {
+	items := []keybindingsdialog.Item{}
+	listed := map[string]bool{}
+	for _, command := range listMenuCommands() {
+		listed[command.ID] = true
+		items = append(items, keybindingsdialog.Item{
+			ID:    command.ID,
+			Title: command.Title,
+			Keys:  strings.Join(actionKeys(command.ID), ", "),
+		})
+	}
+
+	others := []string{}
+	for _, mapping := range []map[string]string{dinkyKeyToActionMapping, smidgenKeyToActionMapping} {
+		for _, actionName := range mapping {
+			if actionName != "" && !listed[actionName] {
+				listed[actionName] = true
+				others = append(others, actionName)
+			}
+		}
+	}
+	slices.Sort(others)
+	for _, actionName := range others {
+		items = append(items, keybindingsdialog.Item{
+			ID:    actionName,
+			Title: "Other: " + actionTitle(actionName),
+			Keys:  strings.Join(actionKeys(actionName), ", "),
+		})
+	}
+	return items
+}
+
+// actionTitle turns an action name like `SelectWordLeft` into words.
+func actionTitle(actionName string) string {
+	var title strings.Builder
+	runes := []rune(actionName)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			title.WriteRune(' ')
+		}
+		title.WriteRune(r)
+		if r == ',' || r == '|' {
+			title.WriteRune(' ')
+		}
+	}
+	return title.String()
+}
+
+// commandTitle finds the title shown for an action in the dialog.
+func commandTitle(actionName string) string {
+	for _, command := range listMenuCommands() {
+		if command.ID == actionName {
+			return command.Title
+		}
+	}
+	return actionTitle(actionName)
+}
+
+func bindRecordedKey(actionName string, event *tcell.EventKey) {
+	key := keyEventName(event)
+	if key == "" {
+		keyBindingsDialog.SetMessage("[red]That key can't be bound. Try another one")
+		return
+	}
+	if event.Key() == tcell.KeyRune && event.Modifiers()&^tcell.ModShift == 0 {
+		keyBindingsDialog.SetMessage("[red]Plain characters are needed for typing. Add Ctrl or Alt")
+		return
+	}
+
+	for _, chord := range keyChords {
+		if chord.keys[0] == eventKeyDesc(event) {
+			keyBindingsDialog.SetMessage("[red]" + tview.Escape("'"+key+"' starts the chord '"+
+				strings.Join(chord.names, " ")+"'. Take that off first"))
+			return
+		}
+	}
+
+	message := "'" + key + "' is now " + commandTitle(actionName)
+	id, _ := keyChordID(key)
+	if previous := keyActions()[id]; previous != "" && previous != actionName {
+		message += ", instead of " + commandTitle(previous)
+	}
+
+	for _, oldKey := range actionKeys(actionName) {
+		userKeyBindings[oldKey] = ""
+	}
+	// Any other spelling of the same key is replaced.
+	for userKey := range userKeyBindings {
+		if userID, ok := keyChordID(userKey); ok && userID == id {
+			delete(userKeyBindings, userKey)
+		}
+	}
+	userKeyBindings[key] = actionName
+	updateUserKeyBindings(message)
+}
+
+func removeActionKeys(actionName string) {
+	keys := actionKeys(actionName)
+	if len(keys) == 0 {
+		return
+	}
+	for _, key := range keys {
+		userKeyBindings[key] = ""
+	}
+	updateUserKeyBindings(commandTitle(actionName) + " has no key now")
+}
+
+func resetActionKeys(actionName string) {
+	for key, boundAction := range userKeyBindings {
+		id, ok := keyChordID(key)
+		if boundAction == actionName || (boundAction == "" && ok && defaultKeyActions[id] == actionName) {
+			delete(userKeyBindings, key)
+		}
+	}
+	updateUserKeyBindings(commandTitle(actionName) + " has its default keys")
+}
+
+// updateUserKeyBindings saves and applies a change made in the dialog.
+func updateUserKeyBindings(message string) {
+	pruneUserKeyBindings()
+	if err := saveUserKeyBindings(); err != nil {
+		message = "[red]" + tview.Escape(err.Error())
+	} else {
+		message = tview.Escape(message)
+	}
+	reloadKeyBindings()
+	keyBindingsDialog.SetItems(keyBindingItems())
+	keyBindingsDialog.SetMessage(message)
+}
@@ -0,0 +1,195 @@
+package application
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sedwards2009/smidgen"
+)
+
+// How long to wait for the next key of a chord before giving up on it.
+const chordTimeout = 3 * time.Second
+
+// keyChord is a binding made of several keys pressed one after the other,
+// like `Ctrl-k Ctrl-c`.
+type keyChord struct {
+	keys   []smidgen.KeyDesc
+	names  []string // The name of each key, as it is written in the binding
+	action string
+}
+
+var keyChords []keyChord
+
+// The keys of a chord pressed so far.
+var pendingChord []smidgen.KeyDesc
+var pendingChordGeneration int
+
+// parseKeyChord parses a single key name or a chord of key names separated
+// by spaces.
+func parseKeyChord(key string) ([]smidgen.KeyDesc, bool) {
+	names := strings.Fields(key)
+	if len(names) == 0 {
+		return nil, false
+	}
+	keys := []smidgen.KeyDesc{}
+	for _, name := range names {
+		desc, ok := smidgen.ParseKeySequence(name)
+		if !ok {
+			return nil, false
+		}
+		keys = append(keys, desc)
+	}
+	return keys, true
+}
+
+// chordID gives the same string for every spelling of a key or chord.
+func chordID(keys []smidgen.KeyDesc) string {
+	return fmt.Sprint(keys)
+}
+
+// keyChordID parses a key or chord name and returns its chordID.
+func keyChordID(key string) (string, bool) {
+	keys, ok := parseKeyChord(key)
+	if !ok {
+		return "", false
+	}
+	return chordID(keys), true
+}
+
+// isReachableKey returns false for keys which terminals can't send, like
+// Ctrl-Shift-p. Terminals send the same thing for Ctrl and Ctrl-Shift with a
+// letter.
+func isReachableKey(key string) bool {
+	keys, ok := parseKeyChord(key)
+	if !ok {
+		return false
+	}
+	for _, desc := range keys {
+		if desc.KeyCode >= tcell.KeyCtrlA && desc.KeyCode <= tcell.KeyCtrlZ && desc.Modifiers&tcell.ModShift != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isChord(key string) bool {
+	return len(strings.Fields(key)) > 1
+}
+
+// withoutChords returns the single key bindings of a mapping, which are the
+// ones smidgen can handle itself.
+func withoutChords(mapping map[string]string) map[string]string {
+	singles := map[string]string{}
+	for key, action := range mapping {
+		if !isChord(key) {
+			singles[key] = action
+		}
+	}
+	return singles
+}
+
+// eventKeyDesc describes a key press in the same way as parseKeyChord.
+func eventKeyDesc(event *tcell.EventKey) smidgen.KeyDesc {
+	desc := smidgen.KeyDesc{KeyCode: event.Key(), Modifiers: event.Modifiers()}
+	if event.Key() == tcell.KeyRune {
+		desc.R = event.Rune()
+	}
+	return desc
+}
+
+// handleChordKey runs a chord once all of its keys have been pressed. It
+// returns true if the key press was used.
+func handleChordKey(event *tcell.EventKey) bool {
+	if len(pendingChord) != 0 && event.Key() == tcell.KeyEscape {
+		cancelPendingChord()
+		return true
+	}
+
+	keys := append(slices.Clone(pendingChord), eventKeyDesc(event))
+	var prefixOf *keyChord
+	for i, chord := range keyChords {
+		if slices.Equal(chord.keys, keys) {
+			cancelPendingChord()
+			runAction(chord.action)
+			return true
+		}
+		if prefixOf == nil && len(chord.keys) > len(keys) && slices.Equal(chord.keys[:len(keys)], keys) {
+			prefixOf = &keyChords[i]
+		}
+	}
+
+	if prefixOf != nil {
+		pendingChord = keys
+		statusBar.PendingKeys = strings.Join(prefixOf.names[:len(keys)], " ")
+		startChordTimeout()
+		return true
+	}
+
+	if len(pendingChord) != 0 {
+		keyName := keyEventName(event)
+		if keyName == "" {
+			keyName = event.Name()
+		}
+		statusBar.ShowWarning(statusBar.PendingKeys + " " + keyName + " isn't bound to anything")
+		cancelPendingChord()
+		return true
+	}
+	return false
+}
+
+func startChordTimeout() {
+	pendingChordGeneration++
+	generation := pendingChordGeneration
+	time.AfterFunc(chordTimeout, func() {
+		app.QueueUpdateDraw(func() {
+			// Only if no keys have been pressed since.
+			if generation == pendingChordGeneration {
+				cancelPendingChord()
+			}
+		})
+	})
+}
+
+func cancelPendingChord() {
+	pendingChord = nil
+	pendingChordGeneration++
+	statusBar.PendingKeys = ""
+}
+
+// runAction runs a Dinky action or a smidgen action by name.
+func runAction(actionName string) {
+	if dinkyAction, ok := dinkyActionMapping[actionName]; ok {
+		if p := dinkyAction(); p != nil {
+			app.SetFocus(p)
+		}
+		return
+	}
+	runSmidgenActions(actionName)
+}
+
+// runSmidgenActions runs a smidgen action, or a combination of them, in the
+// way smidgen does for its own key bindings. `A,B` runs both, `A|B` runs B
+// only if A fails and `A&B` runs B only if A succeeds.
+func runSmidgenActions(actions string) {
+	for actions != "" {
+		name := actions
+		separator := byte(0)
+		actions = ""
+		if i := strings.IndexAny(name, ",|&"); i != -1 {
+			name, separator, actions = name[:i], name[i], name[i+1:]
+		}
+
+		action := currentFileBuffer.editor.MapActionNameToAction(name)
+		if action == nil {
+			break
+		}
+		success := action()
+		if (separator == '|' && success) || (separator == '&' && !success) {
+			break
+		}
+	}
+	syncMenuFromBuffer(currentFileBuffer.buffer)
+}
@@ -0,0 +1,158 @@
+package application
+
+import (
+	"dinky/internal/application/settingstype"
+	"strings"
+
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen"
+)
+
+// keymapBindings holds the changes each keymap preset makes to the default
+// key bindings. They are written like keybindings.json, which goes on top.
+// Binding a key which starts a chord takes away what that key did on its
+// own.
+var keymapBindings = map[string]map[string]string{
+	"vscode": {
+		"Ctrl-p":        ACTION_OPEN_FILE,
+		"Ctrl-l":        smidgen.ActionSelectLine,
+		"Ctrl-PageDown": ACTION_NEXT_EDITOR,
+		"Ctrl-PageUp":   ACTION_PREVIOUS_EDITOR,
+		"ShiftF3":       ACTION_FIND_PREVIOUS,
+		"Ctrl-k s":      ACTION_SAVE_ALL,
+		"Ctrl-k Ctrl-w": ACTION_CLOSE_ALL,
+		"Ctrl-k o":      ACTION_CLOSE_OTHERS,
+		"Ctrl-k Ctrl-s": ACTION_KEY_BINDINGS,
+		// Terminals can't send Ctrl-Shift with a letter, so these stand in
+		// for Ctrl-Shift-f, Ctrl-Shift-h and Ctrl-Shift-k.
+		"Ctrl-k Ctrl-f": ACTION_FIND_IN_FILES,
+		"Ctrl-k Ctrl-h": ACTION_REPLACE_IN_FILES,
+		"Ctrl-k Ctrl-k": smidgen.ActionDeleteLine,
+	},
+	"sublime": {
+		"Ctrl-p":        ACTION_OPEN_FILE,
+		"Ctrl-l":        smidgen.ActionSelectLine,
+		"Ctrl-k Ctrl-d": smidgen.ActionDuplicateLine, // For Ctrl-Shift-d, which terminals can't send
+		"Ctrl-k Ctrl-k": smidgen.ActionDeleteLine,    // For Ctrl-Shift-k
+		"CtrlShiftUp":   smidgen.ActionMoveLinesUp,
+		"CtrlShiftDown": smidgen.ActionMoveLinesDown,
+		"Ctrl-k Ctrl-u": ACTION_TO_UPPERCASE,
+		"Ctrl-k Ctrl-l": ACTION_TO_LOWERCASE,
+		"F9":            ACTION_SORT_LINES,
+		"CtrlF2":        smidgen.ActionToggleBookmark,
+		"F2":            ACTION_NEXT_BOOKMARK,
+		"ShiftF2":       ACTION_PREVIOUS_BOOKMARK,
+		"ShiftF3":       ACTION_FIND_PREVIOUS,
+		"Ctrl-PageDown": ACTION_NEXT_EDITOR,
+		"Ctrl-PageUp":   ACTION_PREVIOUS_EDITOR,
+	},
+	"nano": {
+		"Ctrl-o":         ACTION_SAVE_FILE,
+		"Ctrl-r":         ACTION_OPEN_FILE,
+		"Ctrl-x":         ACTION_QUIT,
+		"Ctrl-w":         ACTION_FIND,
+		"Alt-w":          ACTION_FIND_NEXT,
+		"Alt-q":          ACTION_FIND_PREVIOUS,
+		"Alt-r":          ACTION_FIND_AND_REPLACE,
+		"CtrlUnderscore": ACTION_GO_TO_LINE,
+		"Ctrl-u":         smidgen.ActionPaste,
+		"Alt-6":          smidgen.ActionCopy,
+		"Alt-u":          smidgen.ActionUndo,
+		"Alt-e":          smidgen.ActionRedo,
+		"Ctrl-a":         smidgen.ActionStartOfTextToggle,
+		"Ctrl-e":         smidgen.ActionEndOfLine,
+		"Ctrl-y":         smidgen.ActionCursorPageUp,
+		"Ctrl-v":         smidgen.ActionCursorPageDown,
+		"Alt-a":          smidgen.ActionSetManualSelectionStart,
+		"Alt-]":          smidgen.ActionJumpToMatchingBrace,
+	},
+	"emacs": {
+		"Ctrl-x Ctrl-f":  ACTION_OPEN_FILE,
+		"Ctrl-x Ctrl-s":  ACTION_SAVE_FILE,
+		"Ctrl-x Ctrl-w":  ACTION_SAVE_FILE_AS,
+		"Ctrl-x s":       ACTION_SAVE_ALL,
+		"Ctrl-x k":       ACTION_CLOSE_FILE,
+		"Ctrl-x Ctrl-c":  ACTION_QUIT,
+		"Ctrl-x Right":   ACTION_NEXT_EDITOR,
+		"Ctrl-x Left":    ACTION_PREVIOUS_EDITOR,
+		"Ctrl-x h":       smidgen.ActionSelectAll,
+		"Ctrl-x u":       smidgen.ActionUndo,
+		"CtrlUnderscore": smidgen.ActionUndo,
+		"Alt-g g":        ACTION_GO_TO_LINE,
+		"Ctrl-s":         ACTION_FIND,
+		"Ctrl-r":         ACTION_FIND_PREVIOUS,
+		"Alt-%":          ACTION_FIND_AND_REPLACE,
+		"Ctrl-w":         smidgen.ActionCut,
+		"Alt-w":          smidgen.ActionCopy,
+		"Ctrl-y":         smidgen.ActionPaste,
+		"Ctrl-a":         smidgen.ActionStartOfLine,
+		"Ctrl-e":         smidgen.ActionEndOfLine,
+		"Ctrl-f":         smidgen.ActionCursorRight,
+		"Ctrl-b":         smidgen.ActionCursorLeft,
+		"Ctrl-n":         smidgen.ActionCursorDown,
+		"Ctrl-p":         smidgen.ActionCursorUp,
+		"Alt-f":          smidgen.ActionWordRight,
+		"Alt-b":          smidgen.ActionWordLeft,
+		"Alt-<":          smidgen.ActionCursorStart,
+		"Alt->":          smidgen.ActionCursorEnd,
+		"Ctrl-v":         smidgen.ActionCursorPageDown,
+		"Alt-v":          smidgen.ActionCursorPageUp,
+		"Ctrl-d":         smidgen.ActionDelete,
+		"Ctrl-l":         smidgen.ActionCenter,
+	},
+}
+
+// applyKeymap changes the default key bindings to those of a keymap preset.
+// The presets are checked like keybindings.json, and any problems are
+// returned to be shown with the user's.
+func applyKeymap(name string) []string {
+	problems := []string{}
+	for _, problem := range applyUserKeyBindings(keymapBindings[name]) {
+		problems = append(problems, "Keymap "+keymapTitle(name)+": "+problem)
+	}
+	return problems
+}
+
+func keymapTitle(name string) string {
+	for _, keymap := range settingstype.Keymaps {
+		if keymap.Name == name {
+			return keymap.Title
+		}
+	}
+	return name
+}
+
+// shortcutReference lists every key binding as Markdown, grouped by menu.
+func shortcutReference() string {
+	var text strings.Builder
+	text.WriteString("# Keyboard Shortcuts\n\n")
+	text.WriteString("Keymap: " + keymapTitle(settings.Keymap) + "\n")
+
+	group := ""
+	for _, item := range keyBindingItems() {
+		if item.Keys == "" {
+			continue
+		}
+		itemGroup, title, _ := strings.Cut(item.Title, ": ")
+		if itemGroup != group {
+			group = itemGroup
+			text.WriteString("\n## " + group + "\n\n")
+			text.WriteString("| Command | Keys |\n")
+			text.WriteString("| ------- | ---- |\n")
+		}
+		text.WriteString("| " + markdownTableEscape(title) + " | " + markdownTableEscape(item.Keys) + " |\n")
+	}
+	return text.String()
+}
+
+func markdownTableEscape(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
+
+func handleShortcutReference() tview.Primitive {
+	fileBuffer := newFile(shortcutReference(), "")
+	fileBuffer.buffer.Settings["filetype"] = "markdown"
+	fileBuffer.buffer.UpdateRules()
+	syncMenuFromBuffer(fileBuffer.buffer)
+	return nil
+}
@@ -2,6 +2,8 @@ package application
 
 import (
 	"dinky/internal/tui/menu"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -16,11 +18,17 @@ func createMenus() []*menu.Menu {
 			{ID: ACTION_OPEN_FILE, Title: "Open", Callback: handleDinkyAction},
 			{ID: ACTION_SAVE_FILE, Title: "Save", Callback: handleDinkyAction},
 			{ID: ACTION_SAVE_FILE_AS, Title: "Save As…", Callback: handleDinkyAction},
+			{ID: ACTION_SAVE_ALL, Title: "Save All", Callback: handleDinkyAction},
+			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_CLOSE_FILE, Title: "Close", Callback: handleDinkyAction},
+			{ID: ACTION_CLOSE_ALL, Title: "Close All", Callback: handleDinkyAction},
+			{ID: ACTION_CLOSE_OTHERS, Title: "Close Others", Callback: handleDinkyAction},
+			{ID: ACTION_CLOSE_TO_THE_RIGHT, Title: "Close to the Right", Callback: handleDinkyAction},
 			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_SUSPEND, Title: "Suspend", Callback: handleDinkyAction},
 			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_SETTINGS, Title: "Settings", Callback: handleDinkyAction},
+			{ID: ACTION_KEY_BINDINGS, Title: "Key Bindings…", Callback: handleDinkyAction},
 			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_QUIT, Title: "Quit", Callback: handleDinkyAction},
 		}},
@@ -36,9 +44,13 @@ func createMenus() []*menu.Menu {
 			{ID: ACTION_FIND_NEXT, Title: "Find Next", Callback: handleDinkyAction},
 			{ID: ACTION_FIND_PREVIOUS, Title: "Find Previous", Callback: handleDinkyAction},
 			{ID: ACTION_FIND_AND_REPLACE, Title: "Find & Replace", Callback: handleDinkyAction},
+			{ID: ACTION_FIND_IN_OPEN_FILES, Title: "Find in Open Files…", Callback: handleDinkyAction},
+			{ID: ACTION_FIND_IN_FILES, Title: "Find in Files…", Callback: handleDinkyAction},
+			{ID: ACTION_REPLACE_IN_FILES, Title: "Replace in Files…", Callback: handleDinkyAction},
 			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_SET_TAB_CHARACTER, Title: "Tab Character…", Callback: handleDinkyAction},
 			{ID: ACTION_SET_LINE_ENDINGS, Title: "Line Endings…", Callback: handleDinkyAction},
+			{ID: ACTION_SET_ENCODING, Title: "Encoding…", Callback: handleDinkyAction},
 			{ID: ACTION_CONVERT_TAB_SPACES, Title: "Convert All Tabs to Spaces", Callback: handleDinkyAction},
 		}},
 		{Title: "[::u]S[::U]election", Shortcut: 's', Items: []*menu.MenuItem{
@@ -64,20 +76,33 @@ func createMenus() []*menu.Menu {
 			{ID: ACTION_NEXT_BOOKMARK, Title: "Next Bookmark", Callback: handleDinkyAction},
 			{ID: ACTION_PREVIOUS_BOOKMARK, Title: "Previous Bookmark", Callback: handleDinkyAction},
 		}},
-		{Title: "[::u]T[::U]ransform", Shortcut: 't', Items: []*menu.MenuItem{
-			{ID: ACTION_TO_UPPERCASE, Title: "To Uppercase", Callback: handleDinkyAction},
-			{ID: ACTION_TO_LOWERCASE, Title: "To Lowercase", Callback: handleDinkyAction},
-			{ID: ACTION_URL_ENCODE, Title: "URL Encode", Callback: handleDinkyAction},
-			{ID: ACTION_URL_DECODE, Title: "URL Decode", Callback: handleDinkyAction},
+		{Title: "[::u]T[::U]ransform", Shortcut: 't', Items: slices.Concat(transformMenuItems(TRANSFORM_MENU), []*menu.MenuItem{
 			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_HARD_WORD_WRAP, Title: "Hard Word Wrap", Callback: handleDinkyAction},
-			{ID: ACTION_SORT_LINES, Title: "Sort Lines", Callback: handleDinkyAction},
+			{ID: ACTION_SORT_LINES, Title: "Sort Lines…", Callback: handleDinkyAction},
 			{ID: ACTION_REVERSE_LINES, Title: "Reverse Lines", Callback: handleDinkyAction},
 			{Title: "", Callback: nil}, // Separator
-			{ID: ACTION_FORMAT_JSON, Title: "Format JSON", Callback: handleDinkyAction},
+			{ID: ACTION_JSON_TO_YAML, Title: "JSON to YAML", Callback: handleDinkyAction},
+			{ID: ACTION_YAML_TO_JSON, Title: "YAML to JSON", Callback: handleDinkyAction},
+			{ID: ACTION_JSON_TO_CSV, Title: "JSON Array to CSV", Callback: handleDinkyAction},
+			{ID: ACTION_CSV_TO_MARKDOWN_TABLE, Title: "CSV/TSV to Markdown Table", Callback: handleDinkyAction},
+			{ID: ACTION_MARKDOWN_TABLE_TO_CSV, Title: "Markdown Table to CSV", Callback: handleDinkyAction},
+			{Title: "", Callback: nil}, // Separator
 			{ID: ACTION_FILTER_EXTERNAL_COMMAND, Title: "Filter via Shell", Callback: handleDinkyAction},
+		})},
+		{Title: "E[::u]n[::U]code", Shortcut: 'n', Items: transformMenuItems(ENCODE_MENU)},
+		{Title: "[::u]D[::U]ata", Shortcut: 'd', Items: []*menu.MenuItem{
+			{ID: ACTION_FORMAT_JSON, Title: "Format JSON", Callback: handleDinkyAction},
+			{ID: ACTION_FORMAT_JSON_SORTED_KEYS, Title: "Format JSON, Sorting Keys", Callback: handleDinkyAction},
+			{ID: ACTION_MINIFY_JSON, Title: "Minify JSON", Callback: handleDinkyAction},
+			{Title: "", Callback: nil}, // Separator
+			{ID: ACTION_FORMAT_XML, Title: "Format XML", Callback: handleDinkyAction},
+			{ID: ACTION_FORMAT_YAML, Title: "Format YAML", Callback: handleDinkyAction},
+			{ID: ACTION_FORMAT_TOML, Title: "Format TOML", Callback: handleDinkyAction},
 		}},
 		{Title: "[::u]V[::U]iew", Shortcut: 'v', Items: []*menu.MenuItem{
+			{ID: ACTION_COMMAND_PALETTE, Title: "Command Palette…", Callback: handleDinkyAction},
+			{Title: "", Callback: nil}, // Separator
 			{ID: smidgen.ActionToggleRuler, Title: "Line Numbers", Callback: handleSmidgenAction},
 			{ID: ACTION_TOGGLE_WHITESPACE, Title: "Show Whitespace", Callback: handleDinkyAction},
 			{ID: ACTION_TOGGLE_TRAILING_WHITESPACE, Title: "Show Trailing Whitespace", Callback: handleDinkyAction},
@@ -88,11 +113,40 @@ func createMenus() []*menu.Menu {
 			{ID: ACTION_SET_SYNTAX_HIGHLIGHTING, Title: "Syntax…", Callback: handleDinkyAction},
 		}},
 		{Title: "[::u]H[::U]elp", Shortcut: 'h', Items: []*menu.MenuItem{
+			{ID: ACTION_SHORTCUT_REFERENCE, Title: "Keyboard Shortcuts", Callback: handleDinkyAction},
 			{ID: ACTION_ABOUT, Title: "About", Callback: handleDinkyAction},
 		}},
 	}
 }
 
+// menuCommand is an action in the menus, with a title like "Edit: Find".
+type menuCommand struct {
+	ID    string
+	Title string
+	Item  *menu.MenuItem
+}
+
+var menuTagRegex = regexp.MustCompile(`\[[^\[\]]*\]`)
+
+// listMenuCommands returns every action in the menus, in menu order.
+func listMenuCommands() []menuCommand {
+	commands := []menuCommand{}
+	seen := map[string]bool{}
+	for _, m := range menus {
+		menuTitle := menuTagRegex.ReplaceAllString(m.Title, "")
+		for _, item := range m.Items {
+			if item.ID == "" || item.Callback == nil || seen[item.ID] {
+				continue
+			}
+			seen[item.ID] = true
+			// Toggles have a check mark or spaces in front of their title.
+			title := strings.TrimLeft(strings.TrimPrefix(item.Title, "\u2713"), " ")
+			commands = append(commands, menuCommand{ID: item.ID, Title: menuTitle + ": " + title, Item: item})
+		}
+	}
+	return commands
+}
+
 func syncMenuKeyBindings(menus []*menu.Menu, smidgenActionToKeyMapping map[string]string) {
 	for _, menu := range menus {
 		for _, menuItem := range menu.Items {
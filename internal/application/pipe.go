@@ -0,0 +1,86 @@
+package application
+
+import (
+	"dinky/internal/application/textencoding"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+)
+
+// readStdin is set when `-` is given on the command line.
+var readStdin bool
+
+// pipeMode is set by `--pipe`. The stdin buffer is written to stdout on exit.
+var pipeMode bool
+
+var stdinFileBuffer *FileBuffer
+var pipeOutput []byte
+
+func readStdinContents() (text string, encodingID string, err error) {
+	contents, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", "", err
+	}
+	encodingID = textencoding.Detect(contents)
+	text, err = textencoding.Decode(contents, encodingID)
+	if err != nil {
+		return "", "", err
+	}
+	return text, encodingID, nil
+}
+
+// isRedirected returns true if stdin or stdout is not a terminal.
+func isRedirected() bool {
+	return !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// newTtyScreen creates a screen on the controlling terminal directly, leaving
+// stdin and stdout free for the pipeline.
+func newTtyScreen() (tcell.Screen, error) {
+	tty, err := tcell.NewDevTty()
+	if err != nil {
+		return nil, err
+	}
+	return tcell.NewTerminfoScreenFromTty(tty)
+}
+
+func loadStdinFileBuffer(text string, encodingID string) {
+	stdinFileBuffer = newFile(text, "")
+	stdinFileBuffer.encoding = encodingID
+	syncEncoding(menus, stdinFileBuffer.encoding)
+	tabBarLine.SetTabTitle(stdinFileBuffer.uuid, "[stdin]")
+}
+
+// capturePipeOutput remembers the contents of the stdin buffer as it is
+// closed, ready to be written to stdout on exit.
+func capturePipeOutput(fileBuffer *FileBuffer) {
+	if !isPipeFileBuffer(fileBuffer) {
+		return
+	}
+	contents, err := textencoding.Encode(bufferToBytes(fileBuffer.buffer), fileBuffer.encoding)
+	if err != nil {
+		contents = bufferToBytes(fileBuffer.buffer)
+	}
+	pipeOutput = contents
+}
+
+func writePipeOutput() {
+	if !pipeMode {
+		return
+	}
+	if stdinFileBuffer != nil && getFileBufferByID(stdinFileBuffer.uuid) != nil {
+		capturePipeOutput(stdinFileBuffer)
+	}
+	if _, err := os.Stdout.Write(pipeOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
+	}
+}
+
+// isPipeFileBuffer returns true for the buffer which will be written to
+// stdout. It never needs saving.
+func isPipeFileBuffer(fileBuffer *FileBuffer) bool {
+	return pipeMode && fileBuffer == stdinFileBuffer
+}
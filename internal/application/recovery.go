@@ -0,0 +1,248 @@
+package application
+
+import (
+	"crypto/sha256"
+	"dinky/internal/tui/dialog"
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/renameio/v2"
+	"github.com/rivo/tview"
+)
+
+const recoveryAutosaveInterval = 30 * time.Second
+
+// recoveryFile is the on-disk format of an autosaved buffer.
+type recoveryFile struct {
+	Filename string    `json:"filename"` // Empty for Untitled buffers
+	Contents string    `json:"contents"`
+	SavedAt  time.Time `json:"savedAt"`
+	PID      int       `json:"pid"`
+}
+
+type recoveryEntry struct {
+	path string
+	file recoveryFile
+}
+
+func recoveryDirPath() string {
+	settingsDir := userSettingsDirPath()
+	if settingsDir == "" {
+		return ""
+	}
+	return filepath.Join(settingsDir, "recovery")
+}
+
+func recoveryFilePath(fileBuffer *FileBuffer) string {
+	return filepath.Join(recoveryDirPath(), fileBuffer.uuid+".json")
+}
+
+// startRecoveryAutosave periodically writes modified buffers to the recovery
+// directory, and also writes them out if we are killed by the terminal going away.
+func startRecoveryAutosave() {
+	go func() {
+		ticker := time.NewTicker(recoveryAutosaveInterval)
+		for range ticker.C {
+			app.QueueUpdate(saveRecoveryFiles)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		<-signals
+		done := make(chan struct{})
+		app.QueueUpdate(func() {
+			saveRecoveryFiles()
+			close(done)
+		})
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+		}
+		app.Stop()
+	}()
+}
+
+// saveRecoveryFiles writes a recovery file for every modified buffer which
+// changed since the last autosave, and removes those which are now clean.
+func saveRecoveryFiles() {
+	recoveryDir := recoveryDirPath()
+	if recoveryDir == "" {
+		return
+	}
+
+	for _, fileBuffer := range fileBuffers {
+		if !fileBuffer.buffer.Modified() {
+			removeRecoveryFile(fileBuffer)
+			continue
+		}
+
+		contents := bufferToBytes(fileBuffer.buffer)
+		hash := sha256.Sum256(contents)
+		if fileBuffer.hasRecoveryFile && hash == fileBuffer.recoveryHash {
+			continue
+		}
+
+		if err := os.MkdirAll(recoveryDir, 0700); err != nil {
+			log.Printf("Failed to create recovery directory: %v", err)
+			return
+		}
+
+		data, err := json.Marshal(recoveryFile{
+			Filename: fileBuffer.filename,
+			Contents: string(contents),
+			SavedAt:  time.Now(),
+			PID:      os.Getpid(),
+		})
+		if err != nil {
+			log.Printf("Failed to encode recovery file: %v", err)
+			continue
+		}
+		if err := renameio.WriteFile(recoveryFilePath(fileBuffer), data, 0600); err != nil {
+			log.Printf("Failed to write recovery file: %v", err)
+			continue
+		}
+		fileBuffer.hasRecoveryFile = true
+		fileBuffer.recoveryHash = hash
+	}
+}
+
+func removeRecoveryFile(fileBuffer *FileBuffer) {
+	if !fileBuffer.hasRecoveryFile {
+		return
+	}
+	os.Remove(recoveryFilePath(fileBuffer))
+	fileBuffer.hasRecoveryFile = false
+}
+
+// listRecoveryFiles returns the recovery files left behind by Dinky instances
+// which are no longer running, oldest first.
+func listRecoveryFiles() []recoveryEntry {
+	recoveryDir := recoveryDirPath()
+	if recoveryDir == "" {
+		return nil
+	}
+	dirEntries, err := os.ReadDir(recoveryDir)
+	if err != nil {
+		return nil
+	}
+
+	entries := []recoveryEntry{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(recoveryDir, dirEntry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var file recoveryFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			log.Printf("Ignoring unreadable recovery file %s: %v", path, err)
+			continue
+		}
+		if isProcessRunning(file.PID) {
+			continue // Belongs to another Dinky which is still open
+		}
+		entries = append(entries, recoveryEntry{path: path, file: file})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].file.SavedAt.Before(entries[j].file.SavedAt)
+	})
+	return entries
+}
+
+func isProcessRunning(pid int) bool {
+	if pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	return syscall.Kill(pid, 0) == nil
+}
+
+// showRecoveryDialog offers to restore buffers left behind by a previous
+// session. It returns nil if there is nothing to recover.
+func showRecoveryDialog() tview.Primitive {
+	entries := listRecoveryFiles()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	items := []dialog.ListItem{}
+	for _, entry := range entries {
+		name := entry.file.Filename
+		if name == "" {
+			name = "[Untitled]"
+		}
+		items = append(items, dialog.ListItem{
+			Text:  name + "  (" + entry.file.SavedAt.Format("2006-01-02 15:04") + ")",
+			Value: entry.path,
+		})
+	}
+
+	return ShowListDialog(dialog.ListDialogOptions{
+		Title:   "Recover Unsaved Changes",
+		Message: "Unsaved changes from a previous session were found:",
+		Buttons: []string{"Restore", "Discard", "Restore All", "Discard All"},
+		Width:   70,
+		Height:  18,
+		Items:   items,
+		OnCancel: func() {
+			hideListDialog()
+			app.SetFocus(currentFileBuffer.editor)
+		},
+		OnAccept: func(value string, buttonIndex int) {
+			hideListDialog()
+			for _, entry := range entries {
+				switch buttonIndex {
+				case -1, 0:
+					if entry.path == value {
+						restoreRecoveryEntry(entry)
+					}
+				case 1:
+					if entry.path == value {
+						os.Remove(entry.path)
+					}
+				case 2:
+					restoreRecoveryEntry(entry)
+				case 3:
+					os.Remove(entry.path)
+				}
+			}
+
+			if nextFocus := showRecoveryDialog(); nextFocus != nil {
+				app.SetFocus(nextFocus)
+			} else {
+				app.SetFocus(currentFileBuffer.editor)
+			}
+		},
+	})
+}
+
+func restoreRecoveryEntry(entry recoveryEntry) {
+	var fileBuffer *FileBuffer
+	if entry.file.Filename == "" {
+		fileBuffer = newFile("", "")
+	} else if fileBuffer = findFileBufferByFilename(entry.file.Filename); fileBuffer != nil {
+		selectTab(fileBuffer.uuid)
+	} else {
+		loadFile(entry.file.Filename)
+		fileBuffer = fileBuffers[len(fileBuffers)-1]
+	}
+
+	// Applying the recovered text on top of the original keeps the buffer
+	// marked as modified, and lets undo step back to the version on disk.
+	setBufferContents(fileBuffer.buffer, entry.file.Contents)
+	os.Remove(entry.path)
+	saveRecoveryFiles()
+	statusBar.ShowMessage("Restored unsaved changes")
+}
@@ -0,0 +1,188 @@
+// Package remote lets a second Dinky process hand its files over to a Dinky
+// which is already running, using a unix socket.
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+type File struct {
+	Filename string `json:"filename"` // Absolute path
+	Position string `json:"position"` // "line" or "line:col", may be empty
+}
+
+type Request struct {
+	Files []File `json:"files"`
+	Wait  bool   `json:"wait"`
+}
+
+type response struct {
+	Done bool `json:"done"`
+}
+
+// SocketPath returns the path of the socket shared by the current user's
+// Dinky processes. The socket lives in a directory which only the current
+// user can get into, so that other users can't pretend to be Dinky.
+func SocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("dinky-%d", os.Getuid()))
+		if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("dinky-%d.sock", os.Getuid())), nil
+}
+
+// checkPrivateDir returns an error unless dir is a real directory owned by
+// the current user which nobody else can use.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' isn't a directory", dir)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("'%s' belongs to another user", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("'%s' can be used by other users", dir)
+	}
+	return nil
+}
+
+// Send passes a request to the running Dinky. When the request asks to wait,
+// this blocks until the running Dinky has finished with the files. It
+// returns false if there is no Dinky to talk to.
+func Send(request Request) (bool, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return false, err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return true, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return true, err
+	}
+
+	// Wait for the reply. The connection closing early means the other
+	// Dinky has gone away, which counts as done too.
+	bufio.NewReader(conn).ReadBytes('\n')
+	return true, nil
+}
+
+// Server accepts requests from other Dinky processes.
+type Server struct {
+	listener net.Listener
+
+	mutex sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// Listen starts a server on the shared socket. The handler is called from a
+// background goroutine for each request, and must call done once the
+// request has been dealt with. An error is returned if another Dinky is
+// already listening.
+func Listen(handler func(request Request, done func())) (*Server, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, errors.New("another Dinky is already listening")
+	}
+	os.Remove(path) // Left behind by a Dinky which crashed
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	server := &Server{listener: listener, conns: map[net.Conn]bool{}}
+	go server.acceptLoop(handler)
+	return server, nil
+}
+
+func (s *Server) acceptLoop(handler func(request Request, done func())) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+		go s.handleConn(conn, handler)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn, handler func(request Request, done func())) {
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		s.closeConn(conn)
+		return
+	}
+	var request Request
+	if err := json.Unmarshal(line, &request); err != nil {
+		log.Printf("Bad remote request: %v", err)
+		s.closeConn(conn)
+		return
+	}
+
+	var once sync.Once
+	handler(request, func() {
+		once.Do(func() {
+			data, _ := json.Marshal(response{Done: true})
+			conn.Write(append(data, '\n'))
+			s.closeConn(conn)
+		})
+	})
+}
+
+func (s *Server) closeConn(conn net.Conn) {
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+	conn.Close()
+}
+
+// Close stops the server. Any clients which are still waiting are released.
+func (s *Server) Close() {
+	s.listener.Close() // Also removes the socket file
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = map[net.Conn]bool{}
+}
@@ -0,0 +1,140 @@
+package application
+
+import (
+	"dinky/internal/application/remote"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// waitMode is set by `--wait`. Dinky quits once the files given on the
+// command line have been closed.
+var waitMode bool
+
+// remoteMode is set by `--remote`. The files are opened in the Dinky which
+// is already running, if there is one.
+var remoteMode bool
+
+var remoteServer *remote.Server
+
+type fileBufferWaiter struct {
+	fileBufferIDs map[string]bool
+	done          func()
+}
+
+var fileBufferWaiters []*fileBufferWaiter
+
+// waitForFileBuffers calls done once all of the given buffers have been closed.
+func waitForFileBuffers(fileBufferIDs []string, done func()) {
+	waiter := &fileBufferWaiter{fileBufferIDs: map[string]bool{}, done: done}
+	for _, id := range fileBufferIDs {
+		waiter.fileBufferIDs[id] = true
+	}
+	if len(waiter.fileBufferIDs) == 0 {
+		done()
+		return
+	}
+	fileBufferWaiters = append(fileBufferWaiters, waiter)
+}
+
+func notifyFileBufferClosed(fileBufferID string) {
+	remainingWaiters := []*fileBufferWaiter{}
+	for _, waiter := range fileBufferWaiters {
+		delete(waiter.fileBufferIDs, fileBufferID)
+		if len(waiter.fileBufferIDs) == 0 {
+			waiter.done()
+		} else {
+			remainingWaiters = append(remainingWaiters, waiter)
+		}
+	}
+	fileBufferWaiters = remainingWaiters
+}
+
+// waitForFileArguments sets up `--wait` for a Dinky which is not remote
+// controlling another one.
+func waitForFileArguments(fileBufferIDs []string) {
+	if !waitMode || len(fileBufferIDs) == 0 {
+		return
+	}
+	waitForFileBuffers(fileBufferIDs, func() {
+		// Closing the tab may be part of quitting already, so let that finish first.
+		app.QueueUpdateDraw(func() {
+			if isQuitting || len(fileBuffers) == 0 {
+				return
+			}
+			if nextFocus := handleQuit(); nextFocus != nil {
+				app.SetFocus(nextFocus)
+			}
+		})
+	})
+}
+
+// runRemoteClient hands the files from the command line over to the Dinky
+// which is already running. It returns false if there isn't one.
+func runRemoteClient() bool {
+	files := []remote.File{}
+	for _, fileArg := range fileArguments {
+		filename, position := fileArg.filename, fileArg.position
+		if position == "" {
+			filename, position = splitFilePosition(filename)
+		}
+		if absFilename, err := filepath.Abs(filename); err == nil {
+			filename = absFilename
+		}
+		files = append(files, remote.File{Filename: filename, Position: position})
+	}
+
+	sent, err := remote.Send(remote.Request{Files: files, Wait: waitMode})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error talking to the running Dinky: %v\n", err)
+	}
+	return sent
+}
+
+func startRemoteServer() {
+	server, err := remote.Listen(func(request remote.Request, done func()) {
+		app.QueueUpdateDraw(func() {
+			handleRemoteRequest(request, done)
+		})
+	})
+	if err != nil {
+		log.Printf("Not accepting remote requests: %v", err)
+		return
+	}
+	remoteServer = server
+}
+
+func stopRemoteServer() {
+	// Don't leave any `--remote --wait` processes hanging.
+	for _, waiter := range fileBufferWaiters {
+		waiter.done()
+	}
+	fileBufferWaiters = nil
+
+	if remoteServer != nil {
+		remoteServer.Close()
+	}
+}
+
+func handleRemoteRequest(request remote.Request, done func()) {
+	fileBufferIDs := []string{}
+	for _, file := range request.Files {
+		fileBuffer, resultString := openFileArgument(fileArgument{filename: file.Filename, position: file.Position})
+		if resultString != "" {
+			statusBar.ShowWarning(resultString)
+		}
+		fileBufferIDs = append(fileBufferIDs, fileBuffer.uuid)
+	}
+
+	// Don't steal the focus from a dialog.
+	if modalPages.GetPageCount() == 1 {
+		app.SetFocus(currentFileBuffer.editor)
+	}
+
+	if !request.Wait {
+		done()
+		return
+	}
+	waitForFileBuffers(fileBufferIDs, done)
+}
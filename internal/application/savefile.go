@@ -0,0 +1,158 @@
+package application
+
+import (
+	"bytes"
+	"dinky/internal/application/textencoding"
+	"dinky/internal/utility"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sedwards2009/smidgen/micro/buffer"
+)
+
+// saveBufferToFile runs the whole save pipeline for a buffer: the on-save
+// clean ups, encoding, backup of the previous version, the write itself
+// and a check that the file on disk holds what we meant to write. A backup
+// which can't be made doesn't stop the save, and is reported as a warning.
+// The buffer itself only gets the clean ups once the file has been written.
+func saveBufferToFile(filename string, buf *buffer.Buffer, encodingID string) (string, error) {
+	contents, err := textencoding.Encode(onSaveBytes(buf), encodingID)
+	if err != nil {
+		return "", err
+	}
+
+	warning := ""
+	if settings.BackupOnSave {
+		if err := backupFile(filename); err != nil {
+			warning = "Couldn't make a backup: " + err.Error()
+		}
+	}
+
+	if err := utility.WriteFile(filename, contents); err != nil {
+		return "", err
+	}
+
+	written, err := os.ReadFile(filename)
+	if err != nil {
+		return "", errors.New("Couldn't read back the file to check it: " + err.Error())
+	}
+	if !bytes.Equal(written, contents) {
+		return "", errors.New("The file on disk doesn't match what was written")
+	}
+	applyOnSaveSteps(buf)
+	return warning, nil
+}
+
+// onSaveBytes returns the contents of a buffer as they will be after the
+// on-save clean ups, without changing the buffer.
+func onSaveBytes(buf *buffer.Buffer) []byte {
+	lines := [][]byte{}
+	for y := 0; y < buf.LinesNum(); y++ {
+		line := buf.LineBytes(y)
+		if settings.TrimTrailingWhitespaceOnSave {
+			line = bytes.TrimRightFunc(line, unicode.IsSpace)
+		}
+		lines = append(lines, line)
+	}
+	if settings.EnsureFinalNewlineOnSave && len(lines[len(lines)-1]) != 0 {
+		lines = append(lines, nil)
+	}
+
+	lineEnding := []byte("\n")
+	if buf.Settings["fileformat"] == "dos" {
+		lineEnding = []byte("\r\n")
+	}
+	return bytes.Join(lines, lineEnding)
+}
+
+func applyOnSaveSteps(buf *buffer.Buffer) {
+	if settings.TrimTrailingWhitespaceOnSave {
+		trimTrailingWhitespace(buf)
+	}
+	if settings.EnsureFinalNewlineOnSave {
+		ensureFinalNewline(buf)
+	}
+}
+
+func trimTrailingWhitespace(buf *buffer.Buffer) {
+	for y := 0; y < buf.LinesNum(); y++ {
+		line := buf.LineBytes(y)
+		trimmed := bytes.TrimRightFunc(line, unicode.IsSpace)
+		if len(trimmed) == len(line) {
+			continue
+		}
+		start := buffer.Loc{X: utf8.RuneCount(trimmed), Y: y}
+		end := buffer.Loc{X: utf8.RuneCount(line), Y: y}
+		buf.Remove(start, end)
+	}
+}
+
+func ensureFinalNewline(buf *buffer.Buffer) {
+	lastLine := buf.LinesNum() - 1
+	if len(buf.LineBytes(lastLine)) != 0 {
+		buf.Insert(buf.End(), "\n")
+	}
+}
+
+// backupPath returns where the previous version of a file is kept. This is
+// `file~` next to the file when backupDir is empty, or else the full path
+// with slashes swapped for `%` inside backupDir.
+func backupPath(filename string, backupDir string) (string, error) {
+	if backupDir == "" {
+		return filename + "~", nil
+	}
+
+	if strings.HasPrefix(backupDir, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		backupDir = filepath.Join(homeDir, backupDir[2:])
+	}
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(backupDir, strings.ReplaceAll(absFilename, string(filepath.Separator), "%")), nil
+}
+
+func backupFile(filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // Nothing to back up yet
+		}
+		return err
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	err = writeBackup(filename, settings.BackupDirectory, contents, info.Mode().Perm())
+	if err != nil && settings.BackupDirectory == "" && userSettingsDirPath() != "" {
+		// We may not be allowed to create files next to this one.
+		err = writeBackup(filename, filepath.Join(userSettingsDirPath(), "backups"), contents, info.Mode().Perm())
+	}
+	return err
+}
+
+func writeBackup(filename string, backupDir string, contents []byte, perm fs.FileMode) error {
+	path, err := backupPath(filename, backupDir)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, contents, perm); err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
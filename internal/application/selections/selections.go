@@ -0,0 +1,162 @@
+package selections
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/sedwards2009/smidgen"
+	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/util"
+)
+
+// Error is a failure to replace one of the selections.
+type Error struct {
+	Start buffer.Loc // Where the selection starts
+	Text  string     // The selected text
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type selection struct {
+	cursor      *buffer.Cursor
+	start       buffer.Loc
+	end         buffer.Loc
+	text        string
+	replacement string
+}
+
+// HasSelection returns true if any of the cursors has text selected.
+func HasSelection(editor *smidgen.View) bool {
+	return slices.ContainsFunc(editor.Buffer().GetCursors(), func(cursor *buffer.Cursor) bool {
+		return cursor.HasSelection()
+	})
+}
+
+// Count returns the number of cursors which have text selected.
+func Count(editor *smidgen.View) int {
+	count := 0
+	for _, cursor := range editor.Buffer().GetCursors() {
+		if cursor.HasSelection() {
+			count++
+		}
+	}
+	return count
+}
+
+// Replace replaces the text selected by each cursor with the result of
+// replace, and selects the new text. Cursors without a selection are left
+// alone. If replace fails on any of the selections then nothing is changed
+// and an *Error is returned. The changes are undone together in one step.
+func Replace(editor *smidgen.View, replace func(text string) (string, error)) error {
+	buf := editor.Buffer()
+
+	cursorSelections := []*selection{}
+	for _, cursor := range buf.GetCursors() {
+		if !cursor.HasSelection() {
+			continue
+		}
+		start, end := cursor.CurSelection[0], cursor.CurSelection[1]
+		if start.GreaterThan(end) {
+			start, end = end, start
+		}
+		cursorSelections = append(cursorSelections, &selection{cursor: cursor, start: start, end: end})
+	}
+	if len(cursorSelections) == 0 {
+		return nil
+	}
+	slices.SortFunc(cursorSelections, func(a, b *selection) int {
+		return compareLocs(a.start, b.start)
+	})
+	// A selection which overlaps the one before it is left alone.
+	selections := []*selection{cursorSelections[0]}
+	for _, s := range cursorSelections[1:] {
+		if !s.start.LessThan(selections[len(selections)-1].end) {
+			selections = append(selections, s)
+		}
+	}
+
+	// Work everything out before changing anything.
+	gaps := make([]int, len(selections))
+	for i, s := range selections {
+		s.text = string(buf.Substr(s.start, s.end))
+		replacement, err := replace(s.text)
+		if err != nil {
+			return &Error{Start: s.start, Text: s.text, Err: err}
+		}
+		s.replacement = replacement
+		if i > 0 {
+			gaps[i] = selections[i-1].end.Diff(s.start, buf)
+		}
+	}
+
+	// Go from the end backwards so that the locations of the selections still
+	// to do don't move.
+	undoSize := buf.UndoStack.Len()
+	for i := len(selections) - 1; i >= 0; i-- {
+		s := selections[i]
+		if s.replacement != s.text {
+			buf.Remove(s.start, s.end)
+			buf.Insert(s.start, s.replacement)
+		}
+	}
+	sameUndoStep(buf, undoSize)
+
+	loc := selections[0].start
+	for i, s := range selections {
+		start := loc.Move(gaps[i], buf)
+		loc = start.Move(util.CharacterCountInString(s.replacement), buf)
+		s.cursor.SetSelectionStart(start)
+		s.cursor.SetSelectionEnd(loc)
+		s.cursor.Loc = loc
+	}
+	editor.Relocate()
+	return nil
+}
+
+// ReplaceLines is like Replace but works on the lines of each selection. A
+// selection which ends at the start of a line doesn't take in that line.
+func ReplaceLines(editor *smidgen.View, replace func(lines []string) ([]string, error)) error {
+	return Replace(editor, func(text string) (string, error) {
+		lastLine := ""
+		if strings.HasSuffix(text, "\n") {
+			text = strings.TrimSuffix(text, "\n")
+			lastLine = "\n"
+		}
+		lines, err := replace(strings.Split(text, "\n"))
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(lines, "\n") + lastLine, nil
+	})
+}
+
+// sameUndoStep gives the changes made since the undo stack had undoSize
+// events the same time, so that they are undone together.
+func sameUndoStep(buf *buffer.Buffer, undoSize int) {
+	top := buf.UndoStack.Top
+	if top == nil {
+		return
+	}
+	element := top
+	for i := buf.UndoStack.Len(); i > undoSize && element != nil; i-- {
+		element.Value.Time = top.Value.Time
+		element = element.Next
+	}
+}
+
+func compareLocs(a, b buffer.Loc) int {
+	if a.LessThan(b) {
+		return -1
+	}
+	if b.LessThan(a) {
+		return 1
+	}
+	return 0
+}
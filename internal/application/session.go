@@ -0,0 +1,161 @@
+package application
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/renameio/v2"
+	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/display"
+)
+
+// restoreSession is set by the `--restore` command line option.
+var restoreSession bool
+
+// session is the on-disk format of the set of open tabs.
+type session struct {
+	ActiveTab int             `json:"activeTab"`
+	Buffers   []sessionBuffer `json:"buffers"`
+}
+
+type sessionBuffer struct {
+	Filename  string `json:"filename"`           // Empty for Untitled buffers
+	Contents  string `json:"contents,omitempty"` // Only used for Untitled buffers
+	CursorX   int    `json:"cursorX"`
+	CursorY   int    `json:"cursorY"`
+	StartLine int    `json:"startLine"`
+	StartRow  int    `json:"startRow"`
+	Bookmarks []int  `json:"bookmarks,omitempty"`
+	SoftWrap  bool   `json:"softWrap"`
+	TabSize   int    `json:"tabSize"`
+	FileType  string `json:"fileType"`
+}
+
+func sessionFilePath() string {
+	settingsDir := userSettingsDirPath()
+	if settingsDir == "" {
+		return ""
+	}
+	return filepath.Join(settingsDir, "session.json")
+}
+
+// saveSession records the open tabs so that they can be reopened next time.
+func saveSession() {
+	sessionPath := sessionFilePath()
+	if sessionPath == "" {
+		return
+	}
+
+	s := session{Buffers: []sessionBuffer{}}
+	for _, fileBuffer := range fileBuffers {
+		if fileBuffer.onEnter != nil {
+			continue // Search results and the like are stale next time
+		}
+		buf := fileBuffer.buffer
+		entry := sessionBuffer{
+			Filename:  fileBuffer.filename,
+			CursorX:   fileBuffer.editor.Cursor().X,
+			CursorY:   fileBuffer.editor.Cursor().Y,
+			StartLine: fileBuffer.editor.ActionController().GetView().StartLine.Line,
+			StartRow:  fileBuffer.editor.ActionController().GetView().StartLine.Row,
+			SoftWrap:  buf.Settings["softwrap"].(bool),
+			TabSize:   int(buf.Settings["tabsize"].(float64)),
+			FileType:  buf.Settings["filetype"].(string),
+		}
+		for _, bookmark := range buf.Bookmarks {
+			entry.Bookmarks = append(entry.Bookmarks, bookmark.Y)
+		}
+
+		if fileBuffer.filename == "" {
+			contents := bufferToBytes(buf)
+			if len(contents) == 0 {
+				continue // Nothing worth restoring
+			}
+			entry.Contents = string(contents)
+		}
+
+		if fileBuffer.uuid == fileBufferID {
+			s.ActiveTab = len(s.Buffers)
+		}
+		s.Buffers = append(s.Buffers, entry)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sessionPath), os.ModePerm); err != nil {
+		log.Printf("Failed to create settings directory: %v", err)
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("Failed to encode session: %v", err)
+		return
+	}
+	if err := renameio.WriteFile(sessionPath, data, 0600); err != nil {
+		log.Printf("Failed to write session file: %v", err)
+	}
+}
+
+// loadSession reopens the tabs from the last session. It returns the tab
+// which was active, if any, and error messages for files which couldn't be
+// loaded.
+func loadSession() (*FileBuffer, []string) {
+	errorMessages := []string{}
+	sessionPath := sessionFilePath()
+	if sessionPath == "" {
+		return nil, errorMessages
+	}
+	data, err := os.ReadFile(sessionPath)
+	if err != nil {
+		return nil, errorMessages
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Printf("Ignoring unreadable session file: %v", err)
+		return nil, errorMessages
+	}
+
+	var activeFileBuffer *FileBuffer
+	for i, entry := range s.Buffers {
+		var fileBuffer *FileBuffer
+		if entry.Filename == "" {
+			fileBuffer = newFile("", "")
+			setBufferContents(fileBuffer.buffer, entry.Contents)
+		} else {
+			if resultString := loadFile(entry.Filename); resultString != "" {
+				errorMessages = append(errorMessages, resultString)
+			}
+			fileBuffer = fileBuffers[len(fileBuffers)-1]
+		}
+		restoreSessionBufferState(fileBuffer, entry)
+
+		if i == s.ActiveTab {
+			activeFileBuffer = fileBuffer
+		}
+	}
+
+	return activeFileBuffer, errorMessages
+}
+
+func restoreSessionBufferState(fileBuffer *FileBuffer, entry sessionBuffer) {
+	buf := fileBuffer.buffer
+	buf.Settings["softwrap"] = entry.SoftWrap
+	buf.Settings["tabsize"] = float64(cleanTabSize(entry.TabSize))
+	if entry.FileType != "" && entry.FileType != buf.Settings["filetype"] {
+		buf.Settings["filetype"] = entry.FileType
+		buf.UpdateRules()
+	}
+
+	for _, line := range entry.Bookmarks {
+		if line >= 0 && line < buf.LinesNum() {
+			buf.ToggleBookmark(line)
+		}
+	}
+
+	// The saved position may be past the end if the file was changed elsewhere.
+	loc := clampLoc(buf, buffer.Loc{X: entry.CursorX, Y: entry.CursorY})
+	fileBuffer.editor.GoToLoc(loc)
+	startLine := min(max(entry.StartLine, 0), buf.LinesNum()-1)
+	fileBuffer.editor.ActionController().SetStartLine(display.SLoc{Line: startLine, Row: entry.StartRow})
+	syncMenuFromBuffer(buf)
+}
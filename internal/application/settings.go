@@ -42,6 +42,8 @@ func loadSettings(settingsPath string) settingstype.Settings {
 	settings.TabSize = cleanTabSize(settings.TabSize)
 	settings.TabCharacter = cleanTabCharacter(settings.TabCharacter)
 	settings.ColorScheme = cleanColorSchemeName(settings.ColorScheme)
+	settings.HistorySize = max(settings.HistorySize, 0)
+	settings.Keymap = cleanKeymap(settings.Keymap)
 
 	return settings
 }
@@ -85,6 +87,15 @@ func cleanTabCharacter(tabCharacter string) string {
 	return "space"
 }
 
+func cleanKeymap(keymap string) string {
+	for _, preset := range settingstype.Keymaps {
+		if preset.Name == keymap {
+			return keymap
+		}
+	}
+	return "dinky"
+}
+
 func cleanColorSchemeName(colorScheme string) string {
 	if colorScheme == "" {
 		return "default"
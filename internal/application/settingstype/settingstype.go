@@ -10,6 +10,30 @@ type Settings struct {
 	ColorScheme            string  `json:"colorScheme"`
 	ShowTrailingWhitespace bool    `json:"showTrailingWhitespace"`
 	VerticalRuler          float64 `json:"verticalRuler"` // 0.0 means off
+	RestoreSession         bool    `json:"restoreSession"`
+
+	TrimTrailingWhitespaceOnSave bool   `json:"trimTrailingWhitespaceOnSave"`
+	EnsureFinalNewlineOnSave     bool   `json:"ensureFinalNewlineOnSave"`
+	BackupOnSave                 bool   `json:"backupOnSave"`
+	BackupDirectory              string `json:"backupDirectory"` // Empty means `file~` next to the file
+
+	HistorySize int `json:"historySize"` // Entries kept for each find, replace and filter history
+
+	Keymap string `json:"keymap"` // The key binding preset, one of Keymaps
+}
+
+// Keymap is a preset of key bindings which keybindings.json goes on top of.
+type Keymap struct {
+	Name  string // As it is written in the settings file
+	Title string
+}
+
+var Keymaps = []Keymap{
+	{Name: "dinky", Title: "Dinky"},
+	{Name: "vscode", Title: "VSCode"},
+	{Name: "sublime", Title: "Sublime"},
+	{Name: "nano", Title: "nano"},
+	{Name: "emacs", Title: "Emacs-lite"},
 }
 
 func DefaultSettings() Settings {
@@ -23,5 +47,15 @@ func DefaultSettings() Settings {
 		ColorScheme:            "default",
 		ShowTrailingWhitespace: true,
 		VerticalRuler:          0.0,
+		RestoreSession:         false,
+
+		TrimTrailingWhitespaceOnSave: false,
+		EnsureFinalNewlineOnSave:     false,
+		BackupOnSave:                 false,
+		BackupDirectory:              "",
+
+		HistorySize: 20,
+
+		Keymap: "dinky",
 	}
 }
@@ -0,0 +1,225 @@
+package application
+
+import (
+	"cmp"
+	"dinky/internal/application/selections"
+	"dinky/internal/tui/sortdialog"
+	"dinky/internal/tui/style"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+)
+
+const sortDialogName = "sortDialog"
+
+var sortDialog *sortdialog.SortDialog
+
+// What was picked in the Sort dialog last time
+var lastSortChoices sortdialog.Choices
+
+func handleSortLines() tview.Primitive {
+	if !selections.HasSelection(currentFileBuffer.editor) {
+		statusBar.ShowWarning("No text selected")
+		return nil
+	}
+	if sortDialog == nil {
+		sortDialog = sortdialog.NewSortDialog(app)
+	}
+	sortDialog.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+	sortDialog.SetChoices(lastSortChoices)
+
+	modalPages.AddPage(sortDialogName, sortDialog, true, true)
+	sortDialog.Open(sortdialog.SortDialogOptions{
+		OnCancel: closeSortDialog,
+		OnAccept: func(choices sortdialog.Choices, index int) {
+			if index == 1 {
+				closeSortDialog()
+				return
+			}
+			field := 0
+			if text := strings.TrimSpace(choices.Field); text != "" {
+				var err error
+				field, err = strconv.Atoi(text)
+				if err != nil || field < 1 {
+					statusBar.ShowWarning("The field should be a number, starting from 1")
+					return
+				}
+			}
+			lastSortChoices = choices
+			closeSortDialog()
+			sortSelection(choices, field)
+		},
+	})
+	style.StyleSortDialog(sortDialog)
+	return sortDialog
+}
+
+func closeSortDialog() {
+	sortDialog.Close()
+	modalPages.RemovePage(sortDialogName)
+	app.SetFocus(currentFileBuffer.editor)
+}
+
+func sortSelection(choices sortdialog.Choices, field int) {
+	selections.ReplaceLines(currentFileBuffer.editor, func(lines []string) ([]string, error) {
+		return sortLines(lines, choices, field), nil
+	})
+}
+
+// sortLines sorts lines as picked in the Sort dialog. field is the field to
+// sort by, starting from 1, or 0 for the whole line.
+func sortLines(lines []string, choices sortdialog.Choices, field int) []string {
+	result := slices.Clone(lines)
+
+	sameLine := func(line string) string {
+		if choices.IgnoreCase {
+			return strings.ToLower(line)
+		}
+		return line
+	}
+
+	if choices.Order == sortdialog.Shuffle {
+		// The same lines always shuffle to the same order.
+		hash := fnv.New64a()
+		for _, line := range lines {
+			hash.Write([]byte(line + "\n"))
+		}
+		seed := hash.Sum64()
+		rand.New(rand.NewPCG(seed, seed)).Shuffle(len(result), func(i, j int) {
+			result[i], result[j] = result[j], result[i]
+		})
+	} else {
+		sortKey := func(line string) string {
+			return sameLine(lineField(line, field, choices.Delimiter))
+		}
+		compare := strings.Compare
+		switch choices.Compare {
+		case sortdialog.CompareNatural:
+			compare = naturalCompare
+		case sortdialog.CompareNumeric:
+			compare = numericCompare
+		}
+		// Lines which compare the same keep their order.
+		slices.SortStableFunc(result, func(a, b string) int {
+			if choices.Order == sortdialog.Descending {
+				return compare(sortKey(b), sortKey(a))
+			}
+			return compare(sortKey(a), sortKey(b))
+		})
+	}
+
+	counts := map[string]int{}
+	for _, line := range result {
+		counts[sameLine(line)]++
+	}
+	seen := map[string]bool{}
+	output := []string{}
+	countWidth := 0
+	for _, count := range counts {
+		countWidth = max(countWidth, len(strconv.Itoa(count)))
+	}
+	for _, line := range result {
+		key := sameLine(line)
+		switch choices.Output {
+		case sortdialog.OutputUnique:
+			if counts[key] == 1 {
+				output = append(output, line)
+			}
+		case sortdialog.OutputCounts:
+			if !seen[key] {
+				output = append(output, fmt.Sprintf("%*d %s", countWidth, counts[key], line))
+			}
+		default:
+			if !choices.RemoveDuplicates || !seen[key] {
+				output = append(output, line)
+			}
+		}
+		seen[key] = true
+	}
+	return output
+}
+
+// lineField returns a field of a line, counting from 1, or the whole line if
+// field is 0. Fields are separated by the delimiter, or by white space if it
+// is empty.
+func lineField(line string, field int, delimiter string) string {
+	if field == 0 {
+		return line
+	}
+	var fields []string
+	if delimiter == "" {
+		fields = strings.Fields(line)
+	} else {
+		fields = strings.Split(line, delimiter)
+	}
+	if field > len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[field-1])
+}
+
+// naturalCompare compares text with the runs of digits in it compared as
+// numbers, so `file9` comes before `file10` and `v1.2` before `v1.10`.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits := strings.IndexFunc(a, isNotDigit)
+		bDigits := strings.IndexFunc(b, isNotDigit)
+		if aDigits == -1 {
+			aDigits = len(a)
+		}
+		if bDigits == -1 {
+			bDigits = len(b)
+		}
+
+		if aDigits > 0 && bDigits > 0 {
+			aNumber := strings.TrimLeft(a[:aDigits], "0")
+			bNumber := strings.TrimLeft(b[:bDigits], "0")
+			if result := cmp.Compare(len(aNumber), len(bNumber)); result != 0 {
+				return result
+			}
+			if result := strings.Compare(aNumber, bNumber); result != 0 {
+				return result
+			}
+			a, b = a[aDigits:], b[bDigits:]
+			continue
+		}
+
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+		if result := cmp.Compare(aRune, bRune); result != 0 {
+			return result
+		}
+		a, b = a[aSize:], b[bSize:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
+
+// numberPrefix matches a number at the start of some text.
+var numberPrefix = regexp.MustCompile(`^\s*[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// numericCompare compares the numbers at the start of some text. Text which
+// doesn't start with a number goes after text which does.
+func numericCompare(a, b string) int {
+	aNumber, aErr := strconv.ParseFloat(strings.TrimSpace(numberPrefix.FindString(a)), 64)
+	bNumber, bErr := strconv.ParseFloat(strings.TrimSpace(numberPrefix.FindString(b)), 64)
+	switch {
+	case aErr != nil && bErr != nil:
+		return strings.Compare(a, b)
+	case aErr != nil:
+		return 1
+	case bErr != nil:
+		return -1
+	}
+	return cmp.Compare(aNumber, bNumber)
+}
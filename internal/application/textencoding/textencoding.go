@@ -0,0 +1,148 @@
+package textencoding
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const DefaultEncoding = "utf-8"
+
+type Encoding struct {
+	ID    string
+	Title string
+	bom   []byte
+	codec encoding.Encoding // nil for plain UTF-8
+}
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+var utf16LEBOM = []byte{0xff, 0xfe}
+var utf16BEBOM = []byte{0xfe, 0xff}
+
+// Encodings lists the supported encodings in the order they are shown to the user.
+var Encodings = []Encoding{
+	{ID: "utf-8", Title: "UTF-8"},
+	{ID: "utf-8-bom", Title: "UTF-8 BOM", bom: utf8BOM},
+	{ID: "utf-16le", Title: "UTF-16 LE", bom: utf16LEBOM, codec: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{ID: "utf-16be", Title: "UTF-16 BE", bom: utf16BEBOM, codec: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	{ID: "windows-1252", Title: "Windows-1252", codec: charmap.Windows1252},
+	{ID: "iso-8859-1", Title: "ISO-8859-1", codec: charmap.ISO8859_1},
+	{ID: "iso-8859-15", Title: "ISO-8859-15", codec: charmap.ISO8859_15},
+}
+
+func find(id string) (Encoding, bool) {
+	for _, e := range Encodings {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Encoding{}, false
+}
+
+// Title returns the display name of an encoding.
+func Title(id string) string {
+	if e, ok := find(id); ok {
+		return e.Title
+	}
+	return id
+}
+
+// Detect guesses the encoding of some file contents. A BOM wins, then UTF-8
+// if the contents are valid, otherwise one of the 8-bit Western encodings.
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return "utf-8-bom"
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return "utf-16le"
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return "utf-16be"
+	}
+
+	if id := detectUTF16WithoutBOM(data); id != "" {
+		return id
+	}
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+
+	// Bytes 0x80-0x9f are control codes in Latin-1 and almost never appear in
+	// text, but they are punctuation like curly quotes in Windows-1252.
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9f {
+			return "windows-1252"
+		}
+	}
+	return "iso-8859-1"
+}
+
+// detectUTF16WithoutBOM spots mostly-ASCII UTF-16 text by the zero bytes
+// which appear in every other position. Such files gain a BOM when saved.
+func detectUTF16WithoutBOM(data []byte) string {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return ""
+	}
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(data) / 2
+	if oddZeros > pairs*3/4 && evenZeros == 0 {
+		return "utf-16le"
+	}
+	if evenZeros > pairs*3/4 && oddZeros == 0 {
+		return "utf-16be"
+	}
+	return ""
+}
+
+// Decode converts file contents in the given encoding to UTF-8. A leading
+// BOM is removed.
+func Decode(data []byte, id string) (string, error) {
+	e, ok := find(id)
+	if !ok {
+		return "", errors.New("unknown encoding '" + id + "'")
+	}
+	if e.bom != nil {
+		data = bytes.TrimPrefix(data, e.bom)
+	}
+	if e.codec == nil {
+		return string(data), nil
+	}
+	decoded, err := e.codec.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// Encode converts UTF-8 text to the given encoding, adding a BOM if the
+// encoding has one. It fails if the text contains characters which the
+// encoding can't represent.
+func Encode(text []byte, id string) ([]byte, error) {
+	e, ok := find(id)
+	if !ok {
+		return nil, errors.New("unknown encoding '" + id + "'")
+	}
+	encoded := text
+	if e.codec != nil {
+		var err error
+		encoded, err = e.codec.NewEncoder().Bytes(text)
+		if err != nil {
+			return nil, errors.New("the text contains characters which can't be saved as " + e.Title)
+		}
+	}
+	if e.bom != nil {
+		encoded = append(append([]byte{}, e.bom...), encoded...)
+	}
+	return encoded, nil
+}
@@ -0,0 +1,317 @@
+package application
+
+import (
+	"dinky/internal/application/selections"
+	"dinky/internal/tui/menu"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+	"golang.org/x/text/unicode/norm"
+)
+
+// textTransform is a named change to the selected text. Each one is an
+// action and gets an item in its menu, and so in the command palette too.
+type textTransform struct {
+	id    string
+	title string
+	menu  string // The menu it is listed in, TRANSFORM_MENU or ENCODE_MENU
+	group string // A separator goes between groups in the menu
+	// perLine applies the transform to each line of the selection on its
+	// own, rather than to the whole selection at once.
+	perLine   bool
+	transform func(text string) (string, error)
+}
+
+const (
+	TRANSFORM_MENU = "Transform"
+	ENCODE_MENU    = "Encode"
+)
+
+// The registered transforms, in menu order.
+var textTransforms []*textTransform
+
+func registerTransform(transform *textTransform) {
+	textTransforms = append(textTransforms, transform)
+	dinkyActionMapping[transform.id] = func() tview.Primitive {
+		transformSelection(transform.perLine, transform.transform)
+		return nil
+	}
+}
+
+func registerTransforms() {
+	registerTransform(&textTransform{id: ACTION_TO_UPPERCASE, title: "To Uppercase", menu: TRANSFORM_MENU,
+		group: "case", perLine: true, transform: always(strings.ToUpper)})
+	registerTransform(&textTransform{id: ACTION_TO_LOWERCASE, title: "To Lowercase", menu: TRANSFORM_MENU,
+		group: "case", perLine: true, transform: always(strings.ToLower)})
+	registerTransform(&textTransform{id: ACTION_TO_TITLE_CASE, title: "To Title Case", menu: TRANSFORM_MENU,
+		group: "case", perLine: true, transform: always(toTitleCase)})
+
+	registerTransform(&textTransform{id: ACTION_TO_CAMEL_CASE, title: "To camelCase", menu: TRANSFORM_MENU,
+		group: "identifier", perLine: true, transform: always(toCamelCase)})
+	registerTransform(&textTransform{id: ACTION_TO_SNAKE_CASE, title: "To snake_case", menu: TRANSFORM_MENU,
+		group: "identifier", perLine: true, transform: always(func(s string) string {
+			return joinWords(s, "_", strings.ToLower)
+		})})
+	registerTransform(&textTransform{id: ACTION_TO_KEBAB_CASE, title: "To kebab-case", menu: TRANSFORM_MENU,
+		group: "identifier", perLine: true, transform: always(func(s string) string {
+			return joinWords(s, "-", strings.ToLower)
+		})})
+	registerTransform(&textTransform{id: ACTION_TO_CONSTANT_CASE, title: "To CONSTANT_CASE", menu: TRANSFORM_MENU,
+		group: "identifier", perLine: true, transform: always(func(s string) string {
+			return joinWords(s, "_", strings.ToUpper)
+		})})
+
+	registerTransform(&textTransform{id: ACTION_ROT13, title: "ROT13", menu: TRANSFORM_MENU,
+		group: "rot13", perLine: true, transform: always(rot13)})
+	registerTransform(&textTransform{id: ACTION_UNICODE_NFC, title: "Unicode Compose (NFC)", menu: TRANSFORM_MENU,
+		group: "unicode", transform: always(norm.NFC.String)})
+	registerTransform(&textTransform{id: ACTION_UNICODE_NFD, title: "Unicode Decompose (NFD)", menu: TRANSFORM_MENU,
+		group: "unicode", transform: always(norm.NFD.String)})
+
+	registerTransform(&textTransform{id: ACTION_URL_ENCODE, title: "URL Encode", menu: ENCODE_MENU,
+		group: "url", perLine: true, transform: always(url.QueryEscape)})
+	registerTransform(&textTransform{id: ACTION_URL_DECODE, title: "URL Decode", menu: ENCODE_MENU,
+		group: "url", perLine: true, transform: urlDecode})
+
+	registerTransform(&textTransform{id: ACTION_BASE64_ENCODE, title: "Base64 Encode", menu: ENCODE_MENU,
+		group: "base64", transform: always(func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		})})
+	registerTransform(&textTransform{id: ACTION_BASE64_DECODE, title: "Base64 Decode", menu: ENCODE_MENU,
+		group: "base64", transform: base64Decode})
+
+	registerTransform(&textTransform{id: ACTION_HEX_ENCODE, title: "Hex Encode", menu: ENCODE_MENU,
+		group: "hex", transform: always(func(s string) string {
+			return hex.EncodeToString([]byte(s))
+		})})
+	registerTransform(&textTransform{id: ACTION_HEX_DECODE, title: "Hex Decode", menu: ENCODE_MENU,
+		group: "hex", transform: hexDecode})
+
+	registerTransform(&textTransform{id: ACTION_HTML_ESCAPE, title: "HTML/XML Escape", menu: ENCODE_MENU,
+		group: "html", transform: always(html.EscapeString)})
+	registerTransform(&textTransform{id: ACTION_HTML_UNESCAPE, title: "HTML/XML Unescape", menu: ENCODE_MENU,
+		group: "html", transform: always(html.UnescapeString)})
+
+	registerTransform(&textTransform{id: ACTION_JSON_STRING_ESCAPE, title: "JSON String Escape", menu: ENCODE_MENU,
+		group: "json", transform: jsonStringEscape})
+	registerTransform(&textTransform{id: ACTION_JSON_STRING_UNESCAPE, title: "JSON String Unescape", menu: ENCODE_MENU,
+		group: "json", transform: jsonStringUnescape})
+}
+
+// transformMenuItems returns the items for the transforms in a menu.
+func transformMenuItems(menuTitle string) []*menu.MenuItem {
+	items := []*menu.MenuItem{}
+	group := ""
+	for _, transform := range textTransforms {
+		if transform.menu != menuTitle {
+			continue
+		}
+		if len(items) != 0 && transform.group != group {
+			items = append(items, &menu.MenuItem{Title: "", Callback: nil}) // Separator
+		}
+		group = transform.group
+		items = append(items, &menu.MenuItem{ID: transform.id, Title: transform.title, Callback: handleDinkyAction})
+	}
+	return items
+}
+
+// transformSelection replaces the text selected by each cursor with the
+// result of the transform. If the transform fails on any of it, the text is
+// left as it was and the error is shown.
+func transformSelection(perLine bool, transformFunc func(string) (string, error)) {
+	editor := currentFileBuffer.editor
+	if !selections.HasSelection(editor) {
+		statusBar.ShowWarning("No text selected")
+		return
+	}
+
+	var err error
+	if perLine {
+		err = selections.ReplaceLines(editor, func(lines []string) ([]string, error) {
+			result := make([]string, len(lines))
+			for i, line := range lines {
+				transformed, err := transformFunc(line)
+				if err != nil {
+					return nil, err
+				}
+				result[i] = transformed
+			}
+			return result, nil
+		})
+	} else {
+		// A selection which ends at the start of a line doesn't take in that
+		// line.
+		err = selections.ReplaceLines(editor, func(lines []string) ([]string, error) {
+			transformed, err := transformFunc(strings.Join(lines, "\n"))
+			if err != nil {
+				return nil, err
+			}
+			return strings.Split(transformed, "\n"), nil
+		})
+	}
+	if err != nil {
+		statusBar.ShowWarning(err.Error())
+	}
+}
+
+// always makes a transform which can't fail.
+func always(transformFunc func(string) string) func(string) (string, error) {
+	return func(s string) (string, error) {
+		return transformFunc(s), nil
+	}
+}
+
+func urlDecode(s string) (string, error) {
+	decoded, err := url.QueryUnescape(s)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL encoding: %w", err)
+	}
+	return decoded, nil
+}
+
+func base64Decode(s string) (string, error) {
+	s = strings.Join(strings.Fields(s), "")
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding,
+		base64.RawURLEncoding} {
+
+		var decoded []byte
+		if decoded, err = encoding.DecodeString(s); err == nil {
+			return decodedText(decoded)
+		}
+	}
+	return "", fmt.Errorf("Invalid Base64: %w", err)
+}
+
+func hexDecode(s string) (string, error) {
+	decoded, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return "", fmt.Errorf("Invalid hex: %w", err)
+	}
+	return decodedText(decoded)
+}
+
+// decodedText checks that decoded bytes are text which can go in the editor.
+func decodedText(decoded []byte) (string, error) {
+	if !utf8.Valid(decoded) {
+		return "", errors.New("The decoded data isn't UTF-8 text")
+	}
+	return string(decoded), nil
+}
+
+func jsonStringEscape(s string) (string, error) {
+	var escaped strings.Builder
+	encoder := json.NewEncoder(&escaped)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return "", err
+	}
+	quoted := strings.TrimSuffix(escaped.String(), "\n")
+	return quoted[1 : len(quoted)-1], nil
+}
+
+func jsonStringUnescape(s string) (string, error) {
+	quoted := s
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		quoted = `"` + s + `"`
+	}
+	var unescaped string
+	if err := json.Unmarshal([]byte(quoted), &unescaped); err != nil {
+		return "", fmt.Errorf("Invalid JSON string: %w", err)
+	}
+	return unescaped, nil
+}
+
+func rot13(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, s)
+}
+
+// toTitleCase capitalizes the first letter of each word and lowers the rest.
+func toTitleCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || !(unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]) || runes[i-1] == '\'') {
+			runes[i] = unicode.ToTitle(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+func toCamelCase(s string) string {
+	first := true
+	return joinWords(s, "", func(word string) string {
+		if first {
+			first = false
+			return strings.ToLower(word)
+		}
+		r, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToTitle(r)) + strings.ToLower(word[size:])
+	})
+}
+
+// joinWords splits the identifiers or words in a line apart, changes each one
+// and joins them with a separator. Indentation and trailing white space are
+// kept.
+func joinWords(line string, separator string, changeWord func(string) string) string {
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	indent := line[:len(line)-len(trimmed)]
+	trimmed = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	trailing := line[len(indent)+len(trimmed):]
+
+	words := splitWords(trimmed)
+	for i, word := range words {
+		words[i] = changeWord(word)
+	}
+	return indent + strings.Join(words, separator) + trailing
+}
+
+// splitWords splits text like `someHTTPServer_name` into the words `some`,
+// `HTTP`, `Server` and `name`.
+func splitWords(s string) []string {
+	words := []string{}
+	runes := []rune(s)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start != -1 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start != -1 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
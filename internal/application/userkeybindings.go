@@ -0,0 +1,288 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/google/renameio/v2"
+	"github.com/sedwards2009/smidgen"
+	"github.com/sedwards2009/smidgen/micro/action"
+)
+
+// userKeyBindings is the contents of keybindings.json. It maps keys to the
+// names of Dinky or smidgen actions. An empty action unbinds the key.
+var userKeyBindings = map[string]string{}
+
+// userKeyBindingsUnreadable stops the key binding editor from overwriting a
+// keybindings.json which couldn't be read.
+var userKeyBindingsUnreadable error
+
+// defaultKeyActions is what each key and chord does without the user's
+// bindings. It is keyed by chordID.
+var defaultKeyActions map[string]string
+
+func userKeyBindingsFilePath() string {
+	settingsDir := userSettingsDirPath()
+	if settingsDir == "" {
+		return ""
+	}
+	return filepath.Join(settingsDir, "keybindings.json")
+}
+
+// loadUserKeyBindings reads keybindings.json and returns any problem reading
+// it.
+func loadUserKeyBindings() []string {
+	userKeyBindings = map[string]string{}
+	userKeyBindingsUnreadable = nil
+
+	path := userKeyBindingsFilePath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			userKeyBindingsUnreadable = err
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	if err := json.Unmarshal(data, &userKeyBindings); err != nil {
+		userKeyBindings = map[string]string{}
+		userKeyBindingsUnreadable = err
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	return nil
+}
+
+func saveUserKeyBindings() error {
+	if userKeyBindingsUnreadable != nil {
+		return fmt.Errorf("Fix keybindings.json first, it couldn't be read: %v", userKeyBindingsUnreadable)
+	}
+	path := userKeyBindingsFilePath()
+	if path == "" {
+		return fmt.Errorf("There is no config directory to save keybindings.json in")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(userKeyBindings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// keyActions returns what each key and chord is bound to, keyed by chordID.
+// Dinky's bindings win over smidgen's, like they do in editorInputCapture.
+func keyActions() map[string]string {
+	actions := map[string]string{}
+	for _, mapping := range []map[string]string{smidgenKeyToActionMapping, dinkyKeyToActionMapping} {
+		for key, action := range mapping {
+			if id, ok := keyChordID(key); ok {
+				actions[id] = action
+			}
+		}
+	}
+	return actions
+}
+
+// applyUserKeyBindings puts the user's bindings on top of the defaults and
+// returns a description of each one which can't be used.
+func applyUserKeyBindings(bindings map[string]string) []string {
+	problems := []string{}
+	bound := map[string]string{}
+	for _, key := range slices.Sorted(maps.Keys(bindings)) {
+		actionName := bindings[key]
+		id, ok := keyChordID(key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("'%s' isn't a key name Dinky knows", key))
+			continue
+		}
+		if actionName != "" && !isReachableKey(key) {
+			problems = append(problems, fmt.Sprintf("'%s' can't be told apart from the same key without Shift", key))
+			continue
+		}
+		if other, ok := bound[id]; ok {
+			problems = append(problems, fmt.Sprintf("'%s' and '%s' are the same key. '%s' is ignored", other, key,
+				key))
+			continue
+		}
+		if actionName != "" && !isKnownAction(actionName) {
+			problems = append(problems, fmt.Sprintf("'%s' is bound to '%s', which isn't an action", key, actionName))
+			continue
+		}
+		bound[id] = key
+
+		unbindKey(id)
+		if actionName == "" {
+			continue
+		}
+		if _, ok := dinkyActionMapping[actionName]; ok {
+			dinkyKeyToActionMapping[key] = actionName
+		} else {
+			smidgenKeyToActionMapping[key] = actionName
+			smidgenSingleLineKeyToActionMapping[key] = actionName
+		}
+	}
+	return append(problems, unbindChordPrefixes(bindings, bound)...)
+}
+
+// unbindChordPrefixes takes away the bindings of keys which start a chord.
+// Those keys wait for the rest of the chord, so they can't do anything on
+// their own. It returns a problem for each of the user's bindings which is
+// lost this way.
+func unbindChordPrefixes(bindings map[string]string, bound map[string]string) []string {
+	prefixes := map[string]string{} // chordID of the start of a chord -> the chord
+	for _, mapping := range []map[string]string{dinkyKeyToActionMapping, smidgenKeyToActionMapping} {
+		for _, key := range slices.Sorted(maps.Keys(mapping)) {
+			keys, ok := parseKeyChord(key)
+			if !ok {
+				continue
+			}
+			for i := 1; i < len(keys); i++ {
+				if _, ok := prefixes[chordID(keys[:i])]; !ok {
+					prefixes[chordID(keys[:i])] = key
+				}
+			}
+		}
+	}
+
+	problems := []string{}
+	for _, key := range slices.Sorted(maps.Keys(bindings)) {
+		id, ok := keyChordID(key)
+		if !ok || bound[id] != key || bindings[key] == "" {
+			continue
+		}
+		if chord, ok := prefixes[id]; ok {
+			problems = append(problems, fmt.Sprintf("'%s' starts the chord '%s', so it can't be bound on its own", key,
+				chord))
+		}
+	}
+	for id := range prefixes {
+		unbindKey(id)
+	}
+	return problems
+}
+
+// unbindKey removes a key or chord from every mapping, however it is spelt
+// there.
+func unbindKey(id string) {
+	for _, mapping := range []map[string]string{smidgenKeyToActionMapping, smidgenSingleLineKeyToActionMapping,
+		dinkyKeyToActionMapping} {
+
+		for key := range mapping {
+			if keyID, ok := keyChordID(key); ok && keyID == id {
+				delete(mapping, key)
+			}
+		}
+	}
+}
+
+// isKnownAction accepts Dinky actions and smidgen actions, including
+// smidgen's `A,B` and `A|B` combinations.
+func isKnownAction(actionName string) bool {
+	if _, ok := dinkyActionMapping[actionName]; ok {
+		return true
+	}
+	for _, part := range strings.FieldsFunc(actionName, func(r rune) bool { return r == ',' || r == '|' || r == '&' }) {
+		if _, ok := action.BufKeyActions[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// actionKeys returns every key bound to an action.
+func actionKeys(actionName string) []string {
+	keys := []string{}
+	for _, mapping := range []map[string]string{dinkyKeyToActionMapping, smidgenKeyToActionMapping} {
+		for key, boundAction := range mapping {
+			if boundAction == actionName && isReachableKey(key) && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// pruneUserKeyBindings drops bindings which are the same as the defaults.
+func pruneUserKeyBindings() {
+	for key, actionName := range userKeyBindings {
+		id, ok := keyChordID(key)
+		if ok && defaultKeyActions[id] == actionName {
+			delete(userKeyBindings, key)
+		}
+	}
+}
+
+// reloadKeyBindings rebuilds the bindings after userKeyBindings has changed
+// and hands them to the editors and menus. Dialogs pick them up when they
+// are next opened.
+func reloadKeyBindings() []string {
+	problems := initKeyBindings()
+	for _, fileBuffer := range fileBuffers {
+		fileBuffer.editor.SetKeybindings(smidgenDefaultKeyBindings)
+		fileBuffer.findbar.SetSmidgenKeybindings(smidgenSingleLineKeyBindings)
+	}
+	syncMenuKeyBindings(menus, actionToKeyMapping)
+	return problems
+}
+
+var specialKeyNames = map[tcell.Key]string{
+	tcell.KeyPgUp:       "PageUp",
+	tcell.KeyPgDn:       "PageDown",
+	tcell.KeyBackspace2: "Backspace",
+	tcell.KeyBackspace:  "OldBackspace",
+}
+
+// keyEventName names a key press the way keybindings.json spells it, or
+// returns "" for keys which can't be bound.
+func keyEventName(event *tcell.EventKey) string {
+	var name string
+	modifiers := event.Modifiers()
+	switch {
+	case event.Key() == tcell.KeyRune:
+		name = string(event.Rune())
+	case event.Key() >= tcell.KeyCtrlA && event.Key() <= tcell.KeyCtrlZ && event.Key() != tcell.KeyBackspace &&
+		event.Key() != tcell.KeyTab && event.Key() != tcell.KeyEnter:
+		name = string(unicode.ToLower(rune('a' + event.Key() - tcell.KeyCtrlA)))
+		modifiers |= tcell.ModCtrl
+	default:
+		var ok bool
+		if name, ok = specialKeyNames[event.Key()]; !ok {
+			if name, ok = tcell.KeyNames[event.Key()]; !ok {
+				return ""
+			}
+		}
+	}
+
+	prefix := ""
+	if modifiers&tcell.ModCtrl != 0 {
+		prefix += "Ctrl-"
+	}
+	if modifiers&(tcell.ModAlt|tcell.ModMeta) != 0 {
+		prefix += "Alt-"
+		modifiers = modifiers&^tcell.ModMeta | tcell.ModAlt
+	}
+	if modifiers&tcell.ModShift != 0 {
+		prefix += "Shift-"
+	}
+	name = prefix + name
+
+	// Only offer names which come back as the same key.
+	desc, ok := smidgen.ParseKeySequence(name)
+	if !ok || desc.KeyCode != event.Key() || desc.Modifiers != modifiers ||
+		(event.Key() == tcell.KeyRune && desc.R != event.Rune()) {
+		return ""
+	}
+	return name
+}
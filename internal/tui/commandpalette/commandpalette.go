@@ -0,0 +1,267 @@
+package commandpalette
+
+import (
+	"dinky/internal/tui/scrollbar"
+	"dinky/internal/tui/smidgeninputfield"
+	"dinky/internal/tui/table2"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen"
+)
+
+type CommandPalette struct {
+	*tview.Flex
+	app *tview.Application
+
+	verticalContentsFlex *tview.Flex
+	innerFlex            *tview.Flex
+
+	InputField        *smidgeninputfield.SmidgenInputField
+	TableField        *table2.Table
+	VerticalScrollbar *scrollbar.Scrollbar
+
+	ItemStyle       tcell.Style
+	KeyStyle        tcell.Style
+	RecentItemStyle tcell.Style
+
+	options CommandPaletteOptions
+	shown   []Command // The commands in the table, in order
+}
+
+type CommandPaletteOptions struct {
+	Commands []Command
+	Recent   []string // IDs of recently used commands, most recent first
+	OnCancel func()
+	OnAccept func(command Command)
+}
+
+// Command is one entry in the palette.
+type Command struct {
+	ID    string
+	Title string // e.g. "Edit: Find"
+	Key   string // The key it is bound to, if any
+}
+
+const CommandPaletteWidth = 70
+const commandPaletteHeight = 20
+
+func NewCommandPalette(app *tview.Application) *CommandPalette {
+	topLayout := tview.NewFlex()
+
+	topLayout.AddItem(nil, 2, 0, false)
+
+	innerFlex := tview.NewFlex()
+	innerFlex.AddItem(nil, 0, 1, false)
+
+	verticalContentsFlex := tview.NewFlex()
+
+	verticalContentsFlex.Box = tview.NewBox() // Nasty hack to clear the `dontClear` flag inside Box.
+	verticalContentsFlex.Box.Primitive = topLayout
+
+	verticalContentsFlex.SetDirection(tview.FlexRow)
+	verticalContentsFlex.SetBorderPadding(1, 1, 1, 1)
+	verticalContentsFlex.SetBorder(true)
+	verticalContentsFlex.SetTitleAlign(tview.AlignLeft)
+	verticalContentsFlex.SetTitle("Command Palette")
+
+	inputField := smidgeninputfield.NewSmidgenInputField(app)
+	verticalContentsFlex.AddItem(inputField, 1, 0, true)
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	tableField := table2.NewTable()
+	tableField.SetSelectable(true, false)
+
+	tableFlex := tview.NewFlex()
+	tableFlex.SetDirection(tview.FlexColumn)
+	tableFlex.SetBorder(false)
+	tableFlex.AddItem(tableField, 0, 1, false)
+
+	verticalScrollbar := scrollbar.NewScrollbar()
+	tableFlex.AddItem(verticalScrollbar, 1, 0, false)
+	verticalContentsFlex.AddItem(tableFlex, 0, 1, false)
+
+	innerFlex.AddItem(verticalContentsFlex, CommandPaletteWidth, 0, true)
+	innerFlex.AddItem(nil, 0, 1, false)
+	innerFlex.SetDirection(tview.FlexColumn)
+
+	topLayout.AddItem(innerFlex, commandPaletteHeight, 0, true)
+	topLayout.AddItem(nil, 0, 1, false)
+	topLayout.SetDirection(tview.FlexRow)
+
+	p := &CommandPalette{
+		Flex:                 topLayout,
+		app:                  app,
+		verticalContentsFlex: verticalContentsFlex,
+		innerFlex:            innerFlex,
+		InputField:           inputField,
+		TableField:           tableField,
+		VerticalScrollbar:    verticalScrollbar,
+		ItemStyle:            tcell.StyleDefault.Foreground(tview.Styles.PrimaryTextColor),
+		KeyStyle:             tcell.StyleDefault.Foreground(tview.Styles.SecondaryTextColor),
+		RecentItemStyle:      tcell.StyleDefault.Foreground(tview.Styles.TertiaryTextColor),
+	}
+
+	verticalScrollbar.Track.SetBeforeDrawFunc(func(_ tcell.Screen) {
+		row, _ := tableField.GetOffset()
+		verticalScrollbar.Track.SetMax(tableField.GetRowCount() - 1)
+		_, _, _, height := tableField.GetInnerRect()
+		verticalScrollbar.Track.SetThumbSize(height)
+		verticalScrollbar.Track.SetPosition(row)
+	})
+	verticalScrollbar.SetChangedFunc(func(position int) {
+		_, column := tableField.GetOffset()
+		tableField.SetOffset(position, column)
+	})
+
+	inputField.SetChangedFunc(func(text string) {
+		p.filter(text)
+	})
+	inputField.SetInputCapture(p.inputFilter)
+	tableField.SetInputCapture(p.inputFilter)
+	tableField.SetDoubleClickFunc(func(row int, _ int) {
+		p.accept()
+	})
+	return p
+}
+
+func (p *CommandPalette) Open(options CommandPaletteOptions) {
+	p.options = options
+	p.InputField.SetText("")
+	p.filter("")
+	p.app.SetFocus(p.InputField)
+}
+
+func (p *CommandPalette) Close() {
+}
+
+func (p *CommandPalette) SetSmidgenKeybindings(keybindings smidgen.Keybindings) {
+	p.InputField.SetKeybindings(keybindings)
+}
+
+// filter fills the table with the commands which match the query. Recently
+// used commands come first, then the rest, best match first.
+func (p *CommandPalette) filter(query string) {
+	type candidate struct {
+		command   Command
+		score     int
+		positions []int
+		recent    int // Position in the recent list, or -1
+	}
+
+	queryRunes := []rune(strings.ReplaceAll(query, " ", ""))
+	candidates := []candidate{}
+	for _, command := range p.options.Commands {
+		score, positions, ok := fuzzyMatch(queryRunes, []rune(command.Title))
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			command:   command,
+			score:     score,
+			positions: positions,
+			recent:    slices.Index(p.options.Recent, command.ID),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.recent != -1) != (b.recent != -1) {
+			return a.recent != -1
+		}
+		if a.recent != -1 {
+			return a.recent < b.recent
+		}
+		return a.score > b.score
+	})
+
+	p.TableField.Clear()
+	p.shown = p.shown[:0]
+	for row, c := range candidates {
+		style := p.ItemStyle
+		if c.recent != -1 {
+			style = p.RecentItemStyle
+		}
+		p.TableField.SetCell(row, 0, &table2.TableCell{
+			Text:  highlightPositions(c.command.Title, c.positions),
+			Style: style,
+		})
+		p.TableField.SetCell(row, 1, &table2.TableCell{
+			Text:  tview.Escape(c.command.Key) + " ",
+			Align: tview.AlignRight,
+			Style: p.KeyStyle,
+		})
+		p.shown = append(p.shown, c.command)
+	}
+	p.TableField.Select(0, 0)
+	p.TableField.ScrollToBeginning()
+}
+
+// highlightPositions underlines the runes of text which the query matched.
+func highlightPositions(text string, positions []int) string {
+	var result strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		if slices.Contains(positions, i) {
+			result.WriteString("[::u]" + tview.Escape(string(r)) + "[::-]")
+		} else {
+			result.WriteString(tview.Escape(string(r)))
+		}
+	}
+	return result.String()
+}
+
+func (p *CommandPalette) accept() {
+	row, _ := p.TableField.GetSelection()
+	if row < 0 || row >= len(p.shown) {
+		return
+	}
+	if p.options.OnAccept != nil {
+		p.options.OnAccept(p.shown[row])
+	}
+}
+
+func (p *CommandPalette) inputFilter(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if p.options.OnCancel != nil {
+			p.options.OnCancel()
+		}
+		return nil
+
+	case tcell.KeyEnter:
+		p.accept()
+		return nil
+
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+		// The selection moves while typing carries on in the input field.
+		if p.InputField.HasFocus() {
+			p.TableField.InputHandler()(event, func(tview.Primitive) {})
+			return nil
+		}
+
+	case tcell.KeyTab, tcell.KeyBacktab:
+		if p.InputField.HasFocus() {
+			p.app.SetFocus(p.TableField)
+		} else {
+			p.app.SetFocus(p.InputField)
+		}
+		return nil
+	}
+	return event
+}
+
+func (p *CommandPalette) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return p.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		p.verticalContentsFlex.MouseHandler()(action, event, setFocus)
+		return true, nil
+	})
+}
+
+// Focus is called when this primitive receives focus.
+func (p *CommandPalette) Focus(delegate func(p tview.Primitive)) {
+	delegate(p.InputField)
+}
@@ -0,0 +1,55 @@
+package commandpalette
+
+import (
+	"unicode"
+)
+
+// fuzzyMatch checks whether every rune of the query appears in the text in
+// order, ignoring case. The score rewards matches at the start of words and
+// runs of consecutive matches, so "fif" ranks "Find in Files" above "Find
+// Previous". The positions are the rune indexes in text which matched.
+func fuzzyMatch(query []rune, text []rune) (score int, positions []int, ok bool) {
+	if len(query) == 0 {
+		return 0, nil, true
+	}
+
+	positions = make([]int, 0, len(query))
+	q := 0
+	lastMatch := -2
+	for i, r := range text {
+		if q == len(query) {
+			break
+		}
+		if unicode.ToLower(r) != unicode.ToLower(query[q]) {
+			continue
+		}
+
+		switch {
+		case i == 0:
+			score += 10
+		case isWordStart(text, i):
+			score += 8
+		}
+		if lastMatch == i-1 {
+			score += 5
+		} else if lastMatch >= 0 {
+			score -= min(i-lastMatch-1, 3) // Small penalty for gaps
+		}
+		lastMatch = i
+		positions = append(positions, i)
+		q++
+	}
+
+	if q < len(query) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
+
+func isWordStart(text []rune, i int) bool {
+	previous := text[i-1]
+	if !unicode.IsLetter(previous) && !unicode.IsDigit(previous) {
+		return true
+	}
+	return unicode.IsLower(previous) && unicode.IsUpper(text[i])
+}
@@ -21,6 +21,7 @@ type ListDialog struct {
 	innerFlex                   *tview.Flex
 	Buttons                     []*tview.Button
 	options                     ListDialogOptions
+	checked                     []bool
 	itemTextColor               tcell.Color
 	itemBackgroundColor         tcell.Color
 	selectedItemBackgroundColor tcell.Color
@@ -34,13 +35,15 @@ type ListDialogOptions struct {
 	Height          int
 	DefaultSelected string
 	Items           []ListItem
+	Checkboxes      bool // Show a checkbox on each item which Space toggles
 	OnCancel        func()
 	OnAccept        func(value string, index int)
 }
 
 type ListItem struct {
-	Text  string
-	Value string
+	Text    string
+	Value   string
+	Checked bool
 }
 
 func NewListDialog(app *tview.Application) *ListDialog {
@@ -119,6 +122,10 @@ func NewListDialog(app *tview.Application) *ListDialog {
 	})
 
 	d.TableField.SetDoubleClickFunc(func(row int, _ int) {
+		if d.options.Checkboxes {
+			d.toggleChecked(row)
+			return
+		}
 		if d.options.OnAccept == nil {
 			return
 		}
@@ -141,12 +148,10 @@ func (d *ListDialog) Open(options ListDialogOptions) {
 
 	// Fill in the table with items
 	d.TableField.Clear()
+	d.checked = make([]bool, len(options.Items))
 	for rowIndex, item := range options.Items {
-		cell := &table2.TableCell{
-			Text:  item.Text,
-			Style: tcell.StyleDefault.Foreground(d.itemTextColor).Background(d.itemBackgroundColor),
-		}
-		d.TableField.SetCell(rowIndex, 0, cell)
+		d.checked[rowIndex] = item.Checked
+		d.setItemCell(rowIndex)
 	}
 
 	d.TableField.Select(0, 0)
@@ -172,6 +177,41 @@ func (d *ListDialog) Open(options ListDialogOptions) {
 func (d *ListDialog) Close() {
 }
 
+func (d *ListDialog) setItemCell(rowIndex int) {
+	text := d.options.Items[rowIndex].Text
+	if d.options.Checkboxes {
+		if d.checked[rowIndex] {
+			text = "[\u2713] " + text
+		} else {
+			text = "[ ] " + text
+		}
+	}
+	cell := &table2.TableCell{
+		Text:  text,
+		Style: tcell.StyleDefault.Foreground(d.itemTextColor).Background(d.itemBackgroundColor),
+	}
+	d.TableField.SetCell(rowIndex, 0, cell)
+}
+
+func (d *ListDialog) toggleChecked(rowIndex int) {
+	if rowIndex < 0 || rowIndex >= len(d.checked) {
+		return
+	}
+	d.checked[rowIndex] = !d.checked[rowIndex]
+	d.setItemCell(rowIndex)
+}
+
+// CheckedValues returns the values of the items which are checked.
+func (d *ListDialog) CheckedValues() []string {
+	values := []string{}
+	for i, item := range d.options.Items {
+		if d.checked[i] {
+			values = append(values, item.Value)
+		}
+	}
+	return values
+}
+
 func (d *ListDialog) inputFilter(event *tcell.EventKey) *tcell.EventKey {
 	switch event.Key() {
 	case tcell.KeyEscape:
@@ -203,6 +243,13 @@ func (d *ListDialog) inputFilter(event *tcell.EventKey) *tcell.EventKey {
 			d.handleTabKey(-1)
 		}
 
+	case tcell.KeyRune:
+		if event.Rune() == ' ' && d.options.Checkboxes && d.TableField.HasFocus() {
+			selection, _ := d.TableField.GetSelection()
+			d.toggleChecked(selection)
+			return nil
+		}
+
 	case tcell.KeyEnter:
 		if d.TableField.HasFocus() {
 			if d.options.OnAccept != nil {
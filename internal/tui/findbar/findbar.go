@@ -3,12 +3,15 @@ package findbar
 import (
 	"dinky/internal/tui/smidgeninputfield"
 	"fmt"
+	"regexp"
 	"slices"
+	"sort"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/sedwards2009/smidgen"
 	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/util"
 )
 
 type Findbar struct {
@@ -16,6 +19,7 @@ type Findbar struct {
 	app                        *tview.Application
 	editor                     *smidgen.View
 	SearchStringField          *smidgeninputfield.SmidgenInputField
+	MatchCountView             *tview.TextView
 	SearchUpButton             *tview.Button
 	SearchDownButton           *tview.Button
 	CloseButton                *tview.Button
@@ -25,28 +29,60 @@ type Findbar struct {
 	ExpanderCheckbox           *tview.Checkbox
 	RegexCheckbox              *tview.Checkbox
 	CaseSensitiveCheckbox      *tview.Checkbox
+	WholeWordCheckbox          *tview.Checkbox
+	InSelectionCheckbox        *tview.Checkbox
+	PreserveCaseCheckbox       *tview.Checkbox
 	OnClose                    func()
 	OnError                    func(err error)
 	OnExpand                   func(expanded bool)
 	OnMessage                  func(message string)
 	OnSearchTextHistoryChange  func(history []string)
 	OnReplaceTextHistoryChange func(history []string)
+	MaxHistorySize             int
 	isExpanded                 bool
 
 	hFlex2 *tview.Flex
 
+	matches       matchIndex
+	searchRegexp  *regexp.Regexp
+	regexpOptions string // The search text and checkboxes searchRegexp was built from
+	regexpErr     error
+
+	// The selection captured when the findbar was opened. The end is kept
+	// relative to the end of its line and of the buffer so that it follows
+	// replacements made inside the selection.
+	hasScope           bool
+	scopeStart         buffer.Loc
+	scopeEndLinesToEnd int
+	scopeEndCharsToEOL int
+
+	origin *searchOrigin // Set while the search text is being typed
+
+	MatchCountStyle        tcell.Style
+	MatchCountInvalidStyle tcell.Style
+
 	recentSearchTextHistory  []string
 	recentReplaceTextHistory []string
 }
 
+const matchCountWidth = 12
+
 func NewFindbar(app *tview.Application, editor *smidgen.View) *Findbar {
 	f := &Findbar{
-		Flex:   tview.NewFlex(),
-		app:    app,
-		editor: editor,
+		Flex:                   tview.NewFlex(),
+		app:                    app,
+		editor:                 editor,
+		MatchCountStyle:        tcell.StyleDefault.Foreground(tview.Styles.PrimaryTextColor).Background(tview.Styles.PrimitiveBackgroundColor),
+		MatchCountInvalidStyle: tcell.StyleDefault.Foreground(tview.Styles.PrimaryTextColor).Background(tview.Styles.PrimitiveBackgroundColor),
+		MaxHistorySize:         10,
 	}
 	f.SetDirection(tview.FlexRow)
 	f.SetBorderPadding(0, 0, 0, 0)
+
+	// Once the user goes back to the editor, Escape leaves the cursor alone.
+	editor.SetFocusFunc(func() {
+		f.origin = nil
+	})
 	f.SetBorder(false)
 
 	hFlex := tview.NewFlex()
@@ -66,30 +102,55 @@ func NewFindbar(app *tview.Application, editor *smidgen.View) *Findbar {
 	searchStringField.SetDoneFunc(func(key tcell.Key) {
 		switch key {
 		case tcell.KeyEscape:
-			if f.OnClose != nil {
-				f.OnClose()
-			}
+			f.cancel()
 		case tcell.KeyEnter:
 			f.SearchDown()
 		}
 	})
+	searchStringField.SetChangedFunc(func(text string) {
+		f.incrementalSearch()
+	})
+	searchStringField.SetHistoryChangedFunc(func(history []string) {
+		f.recentSearchTextHistory = history
+		if f.OnSearchTextHistoryChange != nil {
+			f.OnSearchTextHistoryChange(history)
+		}
+	})
 	hFlex.AddItem(searchStringField, 0, 1, true)
 	f.SearchStringField = searchStringField
 
-	hFlex.AddItem(nil, 1, 0, false)
+	// "3 of 17"
+	matchCountView := tview.NewTextView()
+	matchCountView.SetTextAlign(tview.AlignCenter)
+	hFlex.AddItem(matchCountView, matchCountWidth, 0, false)
+	f.MatchCountView = matchCountView
 
 	// Case Sensitive Checkbox [✓Aa ]
 	caseSensitiveCheckbox := tview.NewCheckbox()
 	caseSensitiveCheckbox.SetChecked(false)
+	caseSensitiveCheckbox.SetChangedFunc(f.handleOptionChange)
 	hFlex.AddItem(caseSensitiveCheckbox, 7, 0, false)
 	f.CaseSensitiveCheckbox = caseSensitiveCheckbox
 
+	// Whole Word Checkbox [✓Word ]
+	wholeWordCheckbox := tview.NewCheckbox()
+	wholeWordCheckbox.SetChangedFunc(f.handleOptionChange)
+	hFlex.AddItem(wholeWordCheckbox, 9, 0, false)
+	f.WholeWordCheckbox = wholeWordCheckbox
+
 	// Regex Checkbox [✓Regex ]
 	regexCheckbox := tview.NewCheckbox()
 	regexCheckbox.SetChecked(false)
+	regexCheckbox.SetChangedFunc(f.handleOptionChange)
 	hFlex.AddItem(regexCheckbox, 10, 0, false)
 	f.RegexCheckbox = regexCheckbox
 
+	// In Selection Checkbox [✓Sel ]
+	inSelectionCheckbox := tview.NewCheckbox()
+	inSelectionCheckbox.SetChangedFunc(f.handleOptionChange)
+	hFlex.AddItem(inSelectionCheckbox, 8, 0, false)
+	f.InSelectionCheckbox = inSelectionCheckbox
+
 	searchUpButton := tview.NewButton("↑") // U+2191 UPWARDS ARROW
 	searchUpButton.SetSelectedFunc(f.SearchUp)
 	f.SearchUpButton = searchUpButton
@@ -106,11 +167,7 @@ func NewFindbar(app *tview.Application, editor *smidgen.View) *Findbar {
 
 	closeButton := tview.NewButton("✕")
 	f.CloseButton = closeButton
-	closeButton.SetSelectedFunc(func() {
-		if f.OnClose != nil {
-			f.OnClose()
-		}
-	})
+	closeButton.SetSelectedFunc(f.close)
 	hFlex.AddItem(closeButton, 3, 0, false)
 
 	f.AddItem(hFlex, 1, 0, true)
@@ -128,18 +185,27 @@ func NewFindbar(app *tview.Application, editor *smidgen.View) *Findbar {
 	replaceStringField.SetDoneFunc(func(key tcell.Key) {
 		switch key {
 		case tcell.KeyEscape:
-			if f.OnClose != nil {
-				f.OnClose()
-			}
+			f.cancel()
 		case tcell.KeyEnter:
 			f.SearchDown()
 		}
 	})
+	replaceStringField.SetHistoryChangedFunc(func(history []string) {
+		f.recentReplaceTextHistory = history
+		if f.OnReplaceTextHistoryChange != nil {
+			f.OnReplaceTextHistoryChange(history)
+		}
+	})
 	f.ReplaceStringField = replaceStringField
 	hFlex2.AddItem(replaceStringField, 0, 1, false)
 
 	hFlex2.AddItem(nil, 1, 0, false)
 
+	// Preserve Case Checkbox [✓Keep Case ]
+	preserveCaseCheckbox := tview.NewCheckbox()
+	hFlex2.AddItem(preserveCaseCheckbox, 14, 0, false)
+	f.PreserveCaseCheckbox = preserveCaseCheckbox
+
 	replaceButton := tview.NewButton("Replace")
 	f.ReplaceButton = replaceButton
 	replaceButton.SetSelectedFunc(f.Replace)
@@ -157,6 +223,14 @@ func NewFindbar(app *tview.Application, editor *smidgen.View) *Findbar {
 	return f
 }
 
+func (f *Findbar) handleOptionChange(checked bool) {
+	if f.origin != nil {
+		f.incrementalSearch()
+	} else {
+		f.UpdateMatches()
+	}
+}
+
 func (f *Findbar) handleExpandClick(checked bool) {
 	if checked {
 		if f.OnExpand != nil {
@@ -204,62 +278,135 @@ func (f *Findbar) SetReplaceTextHistory(history []string) {
 	f.ReplaceStringField.SetHistory(history)
 }
 
-func (f *Findbar) search(directionDown bool) bool {
-	searchText := f.SearchStringField.GetText()
-	if searchText == "" {
-		return false
+// StartSearch is called when the findbar is opened. It remembers the
+// selection for "In Selection" searches and where the cursor is, for
+// incremental search.
+func (f *Findbar) StartSearch() {
+	f.captureOrigin()
+	cursor := f.editor.Cursor()
+	f.hasScope = cursor.HasSelection()
+	if !f.hasScope {
+		return
 	}
-	regex := f.RegexCheckbox.IsChecked()
-	caseSensitive := f.CaseSensitiveCheckbox.IsChecked()
+	start, end := f.selectionRange()
+	buf := f.editor.Buffer()
+	f.scopeStart = start
+	f.scopeEndLinesToEnd = buf.LinesNum() - 1 - end.Y
+	f.scopeEndCharsToEOL = util.CharacterCount(buf.LineBytes(end.Y)) - end.X
+}
 
-	f.updateSearchTextHistory(searchText)
+func (f *Findbar) selectionRange() (buffer.Loc, buffer.Loc) {
+	cursor := f.editor.Cursor()
+	if !cursor.HasSelection() {
+		return cursor.Loc, cursor.Loc
+	}
+	start, end := cursor.CurSelection[0], cursor.CurSelection[1]
+	if start.GreaterThan(end) {
+		start, end = end, start
+	}
+	return start, end
+}
 
-	found, err := f.editor.ActionController().Search(searchText, regex, caseSensitive, directionDown)
+// scopeMatches returns the matches inside the selection when searching
+// "In Selection", and all of them otherwise.
+func (f *Findbar) scopeMatches() [][2]buffer.Loc {
+	matches := f.matches.matches
+	if !f.InSelectionCheckbox.IsChecked() {
+		return matches
+	}
+	if !f.hasScope {
+		return nil
+	}
+	buf := f.editor.Buffer()
+	scopeStart := clampLoc(buf, f.scopeStart)
+	scopeEnd := buffer.Loc{Y: max(buf.LinesNum()-1-f.scopeEndLinesToEnd, 0)}
+	scopeEnd.X = util.CharacterCount(buf.LineBytes(scopeEnd.Y)) - f.scopeEndCharsToEOL
+	scopeEnd = clampLoc(buf, scopeEnd)
+
+	first := sort.Search(len(matches), func(i int) bool {
+		return matches[i][0].GreaterEqual(scopeStart)
+	})
+	last := sort.Search(len(matches), func(i int) bool {
+		return matches[i][1].GreaterThan(scopeEnd)
+	})
+	if last < first {
+		return nil
+	}
+	return matches[first:last]
+}
+
+func clampLoc(buf *buffer.Buffer, loc buffer.Loc) buffer.Loc {
+	loc = loc.Clamp(buf.Start(), buf.End())
+	loc.X = min(max(loc.X, 0), util.CharacterCount(buf.LineBytes(loc.Y)))
+	return loc
+}
+
+// prepareMatches brings the match index up to date and reports any problem
+// with the search.
+func (f *Findbar) prepareMatches() (*regexp.Regexp, [][2]buffer.Loc, bool) {
+	searchText := f.SearchStringField.GetText()
+	if searchText == "" {
+		return nil, nil, false
+	}
+	re, err := f.currentRegexp()
 	if err != nil {
 		if f.OnError != nil {
 			f.OnError(err)
 		}
-		return false
+		return nil, nil, false
+	}
+	f.updateSearchTextHistory(searchText)
+	f.origin = nil // Escape now leaves the cursor where it is
+
+	f.matches.update(f.editor.Buffer(), re)
+	matches := f.scopeMatches()
+	if f.InSelectionCheckbox.IsChecked() && !f.hasScope && f.OnMessage != nil {
+		f.OnMessage("Select some text before opening Find to search in it")
 	}
+	return re, matches, len(matches) != 0
+}
 
-	if !found {
-		// Wrap the cursor around either to the start or end of the buffer
-		prevLoc := f.editor.Cursor().Loc
-		prevStartSelection := f.editor.Cursor().CurSelection[0]
-		prevEndSelection := f.editor.Cursor().CurSelection[1]
+func (f *Findbar) search(directionDown bool) bool {
+	_, matches, ok := f.prepareMatches()
+	if !ok {
+		return false
+	}
 
-		var wrapLoc buffer.Loc
-		if directionDown {
-			wrapLoc = f.editor.Buffer().Start()
-		} else {
-			wrapLoc = f.editor.Buffer().End()
-		}
-		f.editor.Cursor().GotoLoc(wrapLoc)
-		f.editor.Cursor().SetSelectionStart(wrapLoc)
-		f.editor.Cursor().SetSelectionEnd(wrapLoc)
-
-		found, _ := f.editor.ActionController().Search(searchText, regex, caseSensitive, directionDown)
-		if !found {
-			// Restore previous cursor position if not found
-			f.editor.Cursor().GotoLoc(prevLoc)
-			f.editor.Cursor().SetSelectionStart(prevStartSelection)
-			f.editor.Cursor().SetSelectionEnd(prevEndSelection)
-			return false
-		}
+	// Wrap around either to the start or end of the buffer
+	var match [2]buffer.Loc
+	start, end := f.selectionRange()
+	if directionDown {
+		i := sort.Search(len(matches), func(i int) bool {
+			return matches[i][0].GreaterEqual(end)
+		})
+		match = matches[i%len(matches)]
+	} else {
+		i := sort.Search(len(matches), func(i int) bool {
+			return matches[i][0].GreaterEqual(start)
+		})
+		match = matches[(i-1+len(matches))%len(matches)]
 	}
-	f.editor.Relocate()
+	f.selectMatch(match)
 	return true
 }
 
+func (f *Findbar) selectMatch(match [2]buffer.Loc) {
+	cursor := f.editor.Cursor()
+	cursor.SetSelectionStart(match[0])
+	cursor.SetSelectionEnd(match[1])
+	cursor.OrigSelection[0] = cursor.CurSelection[0]
+	cursor.OrigSelection[1] = cursor.CurSelection[1]
+	cursor.GotoLoc(match[1])
+	f.editor.Relocate()
+}
+
 func (f *Findbar) updateSearchTextHistory(searchText string) {
-	// Add to search history if not already present
-	if !slices.Contains(f.recentSearchTextHistory, searchText) {
-		f.recentSearchTextHistory = append(f.recentSearchTextHistory, searchText)
-		if len(f.recentSearchTextHistory) > 10 {
-			f.recentSearchTextHistory = f.recentSearchTextHistory[1:]
-		}
-		f.SearchStringField.SetHistory(f.recentSearchTextHistory)
+	history := smidgeninputfield.AddToHistory(f.recentSearchTextHistory, searchText, f.MaxHistorySize)
+	if slices.Equal(history, f.recentSearchTextHistory) {
+		return
 	}
+	f.recentSearchTextHistory = history
+	f.SearchStringField.SetHistory(history)
 	if f.OnSearchTextHistoryChange != nil {
 		f.OnSearchTextHistoryChange(f.recentSearchTextHistory)
 	}
@@ -273,65 +420,191 @@ func (f *Findbar) SearchDown() {
 	f.search(true)
 }
 
-func (f *Findbar) Replace() {
+// replaceTemplate returns the replacement text, checking any group
+// references in it when searching with a regex.
+func (f *Findbar) replaceTemplate(re *regexp.Regexp) (string, bool) {
 	replaceText := f.ReplaceStringField.GetText()
 	f.updateReplaceTextHistory(replaceText)
-
-	// Collapse the selection if there is one
-	if f.editor.Cursor().HasSelection() {
-		f.editor.Cursor().Loc = f.editor.Cursor().CurSelection[0]
-		f.editor.Cursor().ResetSelection()
+	if f.RegexCheckbox.IsChecked() {
+		if err := checkReplaceTemplate(re, replaceText); err != nil {
+			if f.OnError != nil {
+				f.OnError(err)
+			}
+			return "", false
+		}
 	}
+	return replaceText, true
+}
 
-	found := f.search(true)
-	if !found {
+func (f *Findbar) Replace() {
+	re, matches, ok := f.prepareMatches()
+	if !ok {
 		return
 	}
-
-	if f.editor.Cursor().HasSelection() {
-		f.editor.Cursor().DeleteSelection()
-		f.editor.Cursor().ResetSelection()
+	replaceText, ok := f.replaceTemplate(re)
+	if !ok {
+		return
 	}
-	f.editor.Buffer().Insert(f.editor.Cursor().Loc, replaceText)
 
-	f.search(true)
+	// Replace the match at or after the start of the selection
+	start, _ := f.selectionRange()
+	i := sort.Search(len(matches), func(i int) bool {
+		return matches[i][0].GreaterEqual(start)
+	})
+	match := matches[i%len(matches)]
 
-	f.editor.Relocate()
+	buf := f.editor.Buffer()
+	deltas := replacementDeltas(buf, re, [][2]buffer.Loc{match}, replaceText, f.RegexCheckbox.IsChecked(),
+		f.PreserveCaseCheckbox.IsChecked())
+	if len(deltas) == 0 {
+		return
+	}
+	buf.MultipleReplace(deltas)
+
+	cursor := f.editor.Cursor()
+	cursor.ResetSelection()
+	cursor.GotoLoc(buffer.Loc{X: match[0].X + util.CharacterCount(deltas[0].Text), Y: match[0].Y})
+	if !f.search(true) {
+		f.editor.Relocate()
+	}
 }
 
 func (f *Findbar) ReplaceAll() {
-	replaceText := f.ReplaceStringField.GetText()
-	f.updateReplaceTextHistory(replaceText)
-
-	regex := f.RegexCheckbox.IsChecked()
-	caseSensitive := f.CaseSensitiveCheckbox.IsChecked()
-	count, err := f.editor.ActionController().ReplaceAll(f.SearchStringField.GetText(), regex, caseSensitive,
-		replaceText)
-	if err != nil {
-		if f.OnError != nil {
-			f.OnError(err)
+	re, matches, ok := f.prepareMatches()
+	if !ok {
+		if f.SearchStringField.GetText() != "" && f.OnMessage != nil {
+			f.OnMessage("Replaced 0 occurrences")
 		}
 		return
 	}
+	replaceText, ok := f.replaceTemplate(re)
+	if !ok {
+		return
+	}
+
+	buf := f.editor.Buffer()
+	deltas := replacementDeltas(buf, re, matches, replaceText, f.RegexCheckbox.IsChecked(),
+		f.PreserveCaseCheckbox.IsChecked())
+	if len(deltas) != 0 {
+		buf.MultipleReplace(deltas)
+		f.editor.Cursor().ResetSelection()
+		f.editor.Cursor().GotoLoc(clampLoc(buf, f.editor.Cursor().Loc))
+		f.editor.Relocate()
+	}
 	if f.OnMessage != nil {
-		f.OnMessage(fmt.Sprintf("Replaced %d occurrences", count))
+		f.OnMessage(fmt.Sprintf("Replaced %d occurrences", len(deltas)))
 	}
 }
 
 func (f *Findbar) updateReplaceTextHistory(replaceText string) {
-	// Add to replace text history if not already present
-	if !slices.Contains(f.recentReplaceTextHistory, replaceText) {
-		f.recentReplaceTextHistory = append(f.recentReplaceTextHistory, replaceText)
-		if len(f.recentReplaceTextHistory) > 10 {
-			f.recentReplaceTextHistory = f.recentReplaceTextHistory[1:]
-		}
-		f.ReplaceStringField.SetHistory(f.recentReplaceTextHistory)
+	history := smidgeninputfield.AddToHistory(f.recentReplaceTextHistory, replaceText, f.MaxHistorySize)
+	if slices.Equal(history, f.recentReplaceTextHistory) {
+		return
 	}
+	f.recentReplaceTextHistory = history
+	f.ReplaceStringField.SetHistory(history)
 	if f.OnReplaceTextHistoryChange != nil {
 		f.OnReplaceTextHistoryChange(f.recentReplaceTextHistory)
 	}
 }
 
+// Draw keeps the match count up to date as the buffer is edited and the
+// cursor moves.
+func (f *Findbar) Draw(screen tcell.Screen) {
+	f.UpdateMatches()
+	f.Flex.Draw(screen)
+}
+
+// UpdateMatches refreshes the match count and turns on highlighting of all of
+// the matches in the editor. It is cheap when nothing has changed.
+func (f *Findbar) UpdateMatches() {
+	buf := f.editor.Buffer()
+	re, err := f.currentRegexp()
+	f.matches.update(buf, re)
+	if re == nil {
+		buf.HighlightSearch = false
+		f.MatchCountView.SetTextStyle(f.MatchCountInvalidStyle)
+		if err != nil {
+			f.MatchCountView.SetText("Bad regex")
+		} else {
+			f.MatchCountView.SetText("")
+		}
+		return
+	}
+
+	// Highlighting goes through FindNext() with the regex and case
+	// sensitivity flags fixed, so give it the final pattern.
+	buf.LastSearch = re.String()
+	buf.LastSearchRegex = true
+	if _, ok := buf.Settings["ignorecase"]; !ok {
+		buf.Settings["ignorecase"] = false // SearchMatch() expects it to be set
+	}
+	buf.HighlightSearch = true
+
+	f.MatchCountView.SetTextStyle(f.MatchCountStyle)
+	f.MatchCountView.SetText(f.matchCountText())
+}
+
+// SearchRegexp returns the regular expression for what is in the findbar,
+// or nil when the search text is empty.
+func (f *Findbar) SearchRegexp() (*regexp.Regexp, error) {
+	return f.currentRegexp()
+}
+
+func (f *Findbar) currentRegexp() (*regexp.Regexp, error) {
+	searchText := f.SearchStringField.GetText()
+	if searchText == "" {
+		return nil, nil
+	}
+	regex := f.RegexCheckbox.IsChecked()
+	caseSensitive := f.CaseSensitiveCheckbox.IsChecked()
+	wholeWord := f.WholeWordCheckbox.IsChecked()
+	options := fmt.Sprintf("%t %t %t %s", regex, caseSensitive, wholeWord, searchText)
+	if options != f.regexpOptions {
+		f.regexpOptions = options
+		f.searchRegexp, f.regexpErr = searchRegexp(searchText, regex, caseSensitive, wholeWord)
+	}
+	return f.searchRegexp, f.regexpErr
+}
+
+func (f *Findbar) matchCountText() string {
+	matches := f.scopeMatches()
+	count := len(matches)
+	total := fmt.Sprint(count)
+	if f.matches.truncated && !f.InSelectionCheckbox.IsChecked() {
+		total += "+"
+	}
+	if count == 0 {
+		return "No matches"
+	}
+
+	if f.editor.Cursor().HasSelection() {
+		start, end := f.selectionRange()
+		if i := indexOfMatch(matches, start, end); i != -1 {
+			return fmt.Sprintf("%d of %s", i+1, total)
+		}
+	}
+	if count == 1 {
+		return "1 match"
+	}
+	return total + " matches"
+}
+
+// cancel closes the findbar and puts the cursor back where it was before an
+// incremental search which is still in progress.
+func (f *Findbar) cancel() {
+	f.restoreOrigin()
+	f.close()
+}
+
+func (f *Findbar) close() {
+	f.origin = nil
+	f.editor.Buffer().HighlightSearch = false
+	if f.OnClose != nil {
+		f.OnClose()
+	}
+}
+
 func (f *Findbar) SetSmidgenKeybindings(keybindings smidgen.Keybindings) {
 	f.SearchStringField.SetKeybindings(keybindings)
 }
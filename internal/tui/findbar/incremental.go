@@ -0,0 +1,72 @@
+package findbar
+
+import (
+	"sort"
+
+	"github.com/sedwards2009/smidgen/micro/buffer"
+)
+
+// searchOrigin is where the cursor was when typing in the search field
+// started. Incremental search looks for the nearest match from here and
+// Escape puts the cursor back.
+type searchOrigin struct {
+	loc       buffer.Loc
+	selection [2]buffer.Loc
+}
+
+func (f *Findbar) captureOrigin() {
+	cursor := f.editor.Cursor()
+	f.origin = &searchOrigin{
+		loc:       cursor.Loc,
+		selection: cursor.CurSelection,
+	}
+}
+
+func (f *Findbar) restoreOrigin() {
+	if f.origin == nil {
+		return
+	}
+	buf := f.editor.Buffer()
+	cursor := f.editor.Cursor()
+	cursor.GotoLoc(clampLoc(buf, f.origin.loc))
+	cursor.SetSelectionStart(clampLoc(buf, f.origin.selection[0]))
+	cursor.SetSelectionEnd(clampLoc(buf, f.origin.selection[1]))
+	f.editor.Relocate()
+}
+
+// incrementalSearch selects the first match at or after the origin as the
+// search text is typed.
+func (f *Findbar) incrementalSearch() {
+	if f.origin == nil {
+		f.captureOrigin()
+	}
+	f.UpdateMatches()
+
+	re, err := f.currentRegexp()
+	if re == nil || err != nil {
+		// Keep the cursor where it is while a regex is half typed
+		if re == nil && err == nil {
+			f.restoreOrigin()
+		}
+		return
+	}
+
+	matches := f.scopeMatches()
+	if len(matches) == 0 {
+		f.restoreOrigin()
+		return
+	}
+
+	start := f.origin.loc
+	if f.origin.selection[0] != f.origin.selection[1] {
+		start = f.origin.selection[0]
+		if start.GreaterThan(f.origin.selection[1]) {
+			start = f.origin.selection[1]
+		}
+	}
+	i := sort.Search(len(matches), func(i int) bool {
+		return matches[i][0].GreaterEqual(start)
+	})
+	f.selectMatch(matches[i%len(matches)])
+	f.UpdateMatches()
+}
@@ -0,0 +1,73 @@
+package findbar
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/util"
+)
+
+// maxIndexedMatches stops a pattern like `.` from building a huge index on
+// a big buffer. The counter shows the count as a lower bound past this.
+const maxIndexedMatches = 100000
+
+// matchIndex holds the location of every match of a pattern in a buffer. It
+// is only rebuilt when the pattern changes or the buffer is edited.
+type matchIndex struct {
+	buf       *buffer.Buffer
+	pattern   string
+	undoTop   *buffer.Element // Changes on every edit, undo and redo
+	matches   [][2]buffer.Loc
+	truncated bool
+}
+
+// update rescans the buffer if needed. A nil regexp clears the index.
+func (m *matchIndex) update(buf *buffer.Buffer, re *regexp.Regexp) {
+	if re == nil {
+		m.buf = nil
+		m.pattern = ""
+		m.undoTop = nil
+		m.matches = nil
+		m.truncated = false
+		return
+	}
+
+	if buf == m.buf && re.String() == m.pattern && buf.UndoStack.Top == m.undoTop {
+		return
+	}
+	m.buf = buf
+	m.pattern = re.String()
+	m.undoTop = buf.UndoStack.Top
+	m.matches = m.matches[:0]
+	m.truncated = false
+
+	for y := 0; y < buf.LinesNum(); y++ {
+		line := buf.LineBytes(y)
+		for _, match := range re.FindAllIndex(line, -1) {
+			if match[0] == match[1] {
+				continue // Empty matches can't be selected or highlighted
+			}
+			if len(m.matches) == maxIndexedMatches {
+				m.truncated = true
+				return
+			}
+			m.matches = append(m.matches, [2]buffer.Loc{
+				{X: util.RunePos(line, match[0]), Y: y},
+				{X: util.RunePos(line, match[1]), Y: y},
+			})
+		}
+	}
+}
+
+// indexOfMatch returns the position of the match which runs from start to
+// end, or -1.
+func indexOfMatch(matches [][2]buffer.Loc, start buffer.Loc, end buffer.Loc) int {
+	i := sort.Search(len(matches), func(i int) bool {
+		return matches[i][0].GreaterEqual(start)
+	})
+	if i < len(matches) && matches[i][0] == start && matches[i][1] == end {
+		return i
+	}
+	return -1
+}
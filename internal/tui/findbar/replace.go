@@ -0,0 +1,213 @@
+package findbar
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sedwards2009/smidgen/micro/buffer"
+	"github.com/sedwards2009/smidgen/micro/util"
+)
+
+// searchRegexp builds the regular expression for the findbar options.
+func searchRegexp(searchText string, isRegex bool, caseSensitive bool, wholeWord bool) (*regexp.Regexp, error) {
+	if !wholeWord {
+		return buffer.CreateRegex(searchText, isRegex, caseSensitive)
+	}
+
+	var pattern string
+	if isRegex {
+		pattern = `\b(?:` + searchText + `)\b`
+	} else {
+		// A word boundary next to punctuation would need a word character on
+		// the other side, so only add them where the text starts or ends
+		// with a word character.
+		pattern = regexp.QuoteMeta(searchText)
+		first, _ := utf8.DecodeRuneInString(searchText)
+		if isWordRune(first) {
+			pattern = `\b` + pattern
+		}
+		last, _ := utf8.DecodeLastRuneInString(searchText)
+		if isWordRune(last) {
+			pattern += `\b`
+		}
+	}
+	return buffer.CreateRegex(pattern, true, caseSensitive)
+}
+
+// isWordRune matches what `\b` in Go regexps counts as a word character.
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+}
+
+// checkReplaceTemplate makes sure that every `$1` or `${name}` in a regex
+// replacement refers to a group in the pattern. Go would silently replace
+// them with nothing otherwise, e.g. `$1x` means the group named "1x".
+func checkReplaceTemplate(re *regexp.Regexp, template string) error {
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			continue
+		}
+		rest := template[i+1:]
+		if rest != "" && rest[0] == '$' {
+			i++
+			continue
+		}
+
+		// Anything else which doesn't look like a reference is kept as is,
+		// just like Expand() does.
+		var name string
+		if rest != "" && rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end == -1 || !isGroupName(rest[1:end]) {
+				continue
+			}
+			name = rest[1:end]
+			i += end + 1
+		} else {
+			end := len(rest)
+			for j, r := range rest {
+				if !isGroupNameRune(r) {
+					end = j
+					break
+				}
+			}
+			if end == 0 {
+				continue
+			}
+			name = rest[:end]
+			i += end
+		}
+
+		if !hasGroup(re, name) {
+			if n := leadingDigits(name); n != "" && n != name {
+				return fmt.Errorf("Replacement refers to group '%s', which doesn't exist. Use '${%s}%s' for group %s followed by text",
+					name, n, name[len(n):], n)
+			}
+			return fmt.Errorf("Replacement refers to group '%s', which doesn't exist in the search pattern", name)
+		}
+	}
+	return nil
+}
+
+func isGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !isGroupNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isGroupNameRune follows the rules used by Expand().
+func isGroupNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func hasGroup(re *regexp.Regexp, name string) bool {
+	if n, err := strconv.Atoi(name); err == nil {
+		return n <= re.NumSubexp()
+	}
+	return re.SubexpIndex(name) != -1
+}
+
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && '0' <= s[end] && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
+
+// expandReplacement works out the text to put in place of one match. The
+// submatch indexes are into line.
+func expandReplacement(re *regexp.Regexp, template string, isRegex bool, preserveCase bool, line []byte,
+	submatch []int) []byte {
+
+	var result []byte
+	if isRegex {
+		result = re.Expand(nil, []byte(template), line, submatch)
+	} else {
+		result = []byte(template)
+	}
+	if preserveCase {
+		result = matchCase(result, line[submatch[0]:submatch[1]])
+	}
+	return result
+}
+
+// matchCase gives the replacement the same case style as the text it
+// replaces, e.g. `bar` in place of `FOO` becomes `BAR` and in place of `Foo`
+// becomes `Bar`.
+func matchCase(replacement []byte, original []byte) []byte {
+	if len(replacement) == 0 {
+		return replacement
+	}
+
+	hasUpper := false
+	hasLower := false
+	for _, r := range string(original) {
+		hasUpper = hasUpper || unicode.IsUpper(r)
+		hasLower = hasLower || unicode.IsLower(r)
+	}
+
+	switch {
+	case hasUpper && !hasLower:
+		return bytes.ToUpper(replacement)
+	case !hasUpper && hasLower:
+		return bytes.ToLower(replacement)
+	}
+
+	first, _ := utf8.DecodeRune(original)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRune(replacement)
+		return append([]byte(string(unicode.ToUpper(r))), replacement[size:]...)
+	}
+	return replacement
+}
+
+// replacementDeltas builds the edits which replace the given matches. The
+// result runs from the end of the buffer backwards so that the edits don't
+// move each other.
+func replacementDeltas(buf *buffer.Buffer, re *regexp.Regexp, matches [][2]buffer.Loc, template string, isRegex bool,
+	preserveCase bool) []buffer.Delta {
+
+	wanted := map[buffer.Loc]buffer.Loc{}
+	for _, match := range matches {
+		wanted[match[0]] = match[1]
+	}
+
+	deltas := []buffer.Delta{}
+	lastLine := -1
+	for i := len(matches) - 1; i >= 0; i-- {
+		y := matches[i][0].Y
+		if y == lastLine {
+			continue
+		}
+		lastLine = y
+
+		line := buf.LineBytes(y)
+		submatches := re.FindAllSubmatchIndex(line, -1)
+		for j := len(submatches) - 1; j >= 0; j-- {
+			submatch := submatches[j]
+			start := buffer.Loc{X: util.RunePos(line, submatch[0]), Y: y}
+			end, ok := wanted[start]
+			if !ok || end.X != util.RunePos(line, submatch[1]) {
+				continue
+			}
+			deltas = append(deltas, buffer.Delta{
+				Text:  expandReplacement(re, template, isRegex, preserveCase, line, submatch),
+				Start: start,
+				End:   end,
+			})
+		}
+	}
+	return deltas
+}
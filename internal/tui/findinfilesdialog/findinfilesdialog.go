@@ -0,0 +1,302 @@
+package findinfilesdialog
+
+import (
+	"dinky/internal/tui/dialog"
+	"dinky/internal/tui/smidgeninputfield"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen"
+)
+
+type FindInFilesDialog struct {
+	*tview.Flex
+	app *tview.Application
+
+	verticalContentsFlex *tview.Flex
+	buttonsFlex          *tview.Flex
+	innerFlex            *tview.Flex
+	replaceFieldFlex     *tview.Flex
+
+	SearchInputField      *smidgeninputfield.SmidgenInputField
+	ReplaceInputField     *smidgeninputfield.SmidgenInputField
+	DirectoryInputField   *smidgeninputfield.SmidgenInputField
+	IncludeInputField     *smidgeninputfield.SmidgenInputField
+	ExcludeInputField     *smidgeninputfield.SmidgenInputField
+	CaseSensitiveCheckbox *tview.Checkbox
+	RegexCheckbox         *tview.Checkbox
+
+	Buttons []*tview.Button
+	options FindInFilesDialogOptions
+}
+
+type FindInFilesDialogOptions struct {
+	Replace  bool // Show the Replace field
+	OnCancel func()
+	OnAccept func(query Query, buttonIndex int)
+}
+
+// Query is what was filled in the dialog.
+type Query struct {
+	SearchText    string
+	ReplaceText   string
+	Directory     string
+	Include       string // Comma separated globs
+	Exclude       string
+	CaseSensitive bool
+	Regex         bool
+}
+
+const FindInFilesDialogWidth = 70
+const findInFilesDialogHeight = 14
+
+func NewFindInFilesDialog(app *tview.Application) *FindInFilesDialog {
+	topLayout := tview.NewFlex()
+
+	topLayout.AddItem(nil, 0, 1, false)
+
+	innerFlex := tview.NewFlex()
+	innerFlex.AddItem(nil, 0, 1, false)
+
+	verticalContentsFlex := tview.NewFlex()
+
+	verticalContentsFlex.Box = tview.NewBox() // Nasty hack to clear the `dontClear` flag inside Box.
+	verticalContentsFlex.Box.Primitive = topLayout
+
+	verticalContentsFlex.SetDirection(tview.FlexRow)
+	verticalContentsFlex.SetBorderPadding(1, 1, 1, 1)
+	verticalContentsFlex.SetBorder(true)
+	verticalContentsFlex.SetTitleAlign(tview.AlignLeft)
+
+	addField := func(label string) (*tview.Flex, *smidgeninputfield.SmidgenInputField) {
+		inputField := smidgeninputfield.NewSmidgenInputField(app)
+		fieldFlex := tview.NewFlex()
+		fieldFlex.SetDirection(tview.FlexColumn)
+		fieldFlex.SetBorder(false)
+
+		labelView := tview.NewTextView()
+		labelView.SetText(label)
+		fieldFlex.AddItem(labelView, 12, 0, false)
+		fieldFlex.AddItem(inputField, 0, 1, true)
+		verticalContentsFlex.AddItem(fieldFlex, 1, 0, false)
+		return fieldFlex, inputField
+	}
+
+	_, searchField := addField("Find: ")
+	replaceFieldFlex, replaceField := addField("Replace: ")
+	_, directoryField := addField("Directory: ")
+	if dir, err := os.Getwd(); err == nil {
+		directoryField.SetText(dir)
+	}
+	_, includeField := addField("Include: ")
+	_, excludeField := addField("Exclude: ")
+
+	checkboxFlex := tview.NewFlex()
+	checkboxFlex.SetDirection(tview.FlexColumn)
+	checkboxFlex.AddItem(nil, 12, 0, false)
+	caseSensitiveCheckbox := tview.NewCheckbox()
+	caseSensitiveCheckbox.SetLabel("Case Sensitive: ")
+	checkboxFlex.AddItem(caseSensitiveCheckbox, 20, 0, false)
+	regexCheckbox := tview.NewCheckbox()
+	regexCheckbox.SetLabel("Regex: ")
+	checkboxFlex.AddItem(regexCheckbox, 0, 1, false)
+	verticalContentsFlex.AddItem(checkboxFlex, 1, 0, false)
+
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	explanationLabel := tview.NewTextView()
+	explanationLabel.SetText("Globs are comma separated, e.g. *.go, docs/**")
+	verticalContentsFlex.AddItem(explanationLabel, 1, 0, false)
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	buttonsFlex := tview.NewFlex()
+	buttonsFlex.SetDirection(tview.FlexColumn)
+	buttonsFlex.SetBorder(false)
+	verticalContentsFlex.AddItem(buttonsFlex, 1, 0, false)
+
+	innerFlex.AddItem(verticalContentsFlex, FindInFilesDialogWidth, 0, true)
+	innerFlex.AddItem(nil, 0, 1, false)
+	innerFlex.SetDirection(tview.FlexColumn)
+
+	topLayout.AddItem(innerFlex, findInFilesDialogHeight, 0, true)
+	topLayout.AddItem(nil, 0, 1, false)
+	topLayout.SetDirection(tview.FlexRow)
+
+	return &FindInFilesDialog{
+		Flex:                  topLayout,
+		app:                   app,
+		verticalContentsFlex:  verticalContentsFlex,
+		innerFlex:             innerFlex,
+		buttonsFlex:           buttonsFlex,
+		replaceFieldFlex:      replaceFieldFlex,
+		SearchInputField:      searchField,
+		ReplaceInputField:     replaceField,
+		DirectoryInputField:   directoryField,
+		IncludeInputField:     includeField,
+		ExcludeInputField:     excludeField,
+		CaseSensitiveCheckbox: caseSensitiveCheckbox,
+		RegexCheckbox:         regexCheckbox,
+	}
+}
+
+func (d *FindInFilesDialog) Open(options FindInFilesDialogOptions) {
+	d.options = options
+
+	buttons := []string{"Find", "Cancel"}
+	d.verticalContentsFlex.SetTitle("Find in Files")
+	height := findInFilesDialogHeight
+	d.verticalContentsFlex.ResizeItem(d.replaceFieldFlex, 0, 0)
+	if options.Replace {
+		buttons = []string{"Preview", "Cancel"}
+		d.verticalContentsFlex.SetTitle("Replace in Files")
+		d.verticalContentsFlex.ResizeItem(d.replaceFieldFlex, 1, 0)
+		height++
+	}
+	d.ResizeItem(d.innerFlex, height, 0)
+
+	onButtonClick := func(button string, index int) {
+		d.options.OnAccept(d.Query(), index)
+	}
+
+	d.Buttons = dialog.CreateButtonsRow(d.buttonsFlex, buttons, onButtonClick)
+	for _, btn := range d.Buttons {
+		btn.SetInputCapture(d.inputFilter)
+	}
+	d.CaseSensitiveCheckbox.SetInputCapture(d.inputFilter)
+	d.RegexCheckbox.SetInputCapture(d.inputFilter)
+	d.SearchInputField.SetInputCapture(d.inputFilter)
+	d.ReplaceInputField.SetInputCapture(d.inputFilter)
+	d.DirectoryInputField.SetInputCapture(d.inputFilter)
+	d.IncludeInputField.SetInputCapture(d.inputFilter)
+	d.ExcludeInputField.SetInputCapture(d.inputFilter)
+
+	d.app.SetFocus(d.SearchInputField)
+}
+
+func (d *FindInFilesDialog) Close() {
+}
+
+// Query returns what is currently filled in.
+func (d *FindInFilesDialog) Query() Query {
+	return Query{
+		SearchText:    d.SearchInputField.GetText(),
+		ReplaceText:   d.ReplaceInputField.GetText(),
+		Directory:     d.DirectoryInputField.GetText(),
+		Include:       d.IncludeInputField.GetText(),
+		Exclude:       d.ExcludeInputField.GetText(),
+		CaseSensitive: d.CaseSensitiveCheckbox.IsChecked(),
+		Regex:         d.RegexCheckbox.IsChecked(),
+	}
+}
+
+func (d *FindInFilesDialog) SetSearchText(text string) {
+	d.SearchInputField.SetText(text)
+}
+
+func (d *FindInFilesDialog) SetRecentSearches(searches []string) {
+	d.SearchInputField.SetHistory(searches)
+}
+
+func (d *FindInFilesDialog) SetRecentReplacements(replacements []string) {
+	d.ReplaceInputField.SetHistory(replacements)
+}
+
+func (d *FindInFilesDialog) SetRecentDirectories(directories []string) {
+	d.DirectoryInputField.SetHistory(directories)
+}
+
+func (d *FindInFilesDialog) inputFieldHasFocus() bool {
+	return d.SearchInputField.HasFocus() || d.ReplaceInputField.HasFocus() || d.DirectoryInputField.HasFocus() ||
+		d.IncludeInputField.HasFocus() || d.ExcludeInputField.HasFocus()
+}
+
+func (d *FindInFilesDialog) inputFilter(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if d.options.OnCancel != nil {
+			d.options.OnCancel()
+		}
+		return nil
+
+	case tcell.KeyLeft:
+		for i := 1; i < len(d.Buttons); i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i-1])
+				return nil
+			}
+		}
+
+	case tcell.KeyRight:
+		for i := 0; i < len(d.Buttons)-1; i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i+1])
+				return nil
+			}
+		}
+
+	case tcell.KeyTab:
+		if event.Modifiers() == tcell.ModNone {
+			d.handleTabKey(1)
+		} else if event.Modifiers() == tcell.ModShift {
+			d.handleTabKey(-1)
+		}
+		return nil
+
+	case tcell.KeyBacktab:
+		d.handleTabKey(-1)
+		return nil
+
+	case tcell.KeyEnter:
+		if d.inputFieldHasFocus() {
+			if d.options.OnAccept != nil {
+				d.options.OnAccept(d.Query(), -1)
+			}
+			return nil
+		}
+	}
+	return event
+}
+
+func (d *FindInFilesDialog) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return d.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		d.verticalContentsFlex.MouseHandler()(action, event, setFocus)
+		return true, nil
+	})
+}
+
+// Focus is called when this primitive receives focus.
+func (d *FindInFilesDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.SearchInputField)
+}
+
+func (d *FindInFilesDialog) widgets() []tview.Primitive {
+	widgets := []tview.Primitive{d.SearchInputField}
+	if d.options.Replace {
+		widgets = append(widgets, d.ReplaceInputField)
+	}
+	widgets = append(widgets, d.DirectoryInputField, d.IncludeInputField, d.ExcludeInputField,
+		d.CaseSensitiveCheckbox, d.RegexCheckbox)
+	for _, btn := range d.Buttons {
+		widgets = append(widgets, btn)
+	}
+	return widgets
+}
+
+func (d *FindInFilesDialog) handleTabKey(direction int) {
+	widgets := d.widgets()
+	for i := 0; i < len(widgets); i++ {
+		if widgets[i].HasFocus() {
+			d.app.SetFocus(widgets[(i+direction+len(widgets))%len(widgets)])
+			return
+		}
+	}
+}
+
+func (d *FindInFilesDialog) SetSmidgenKeybindings(keybindings smidgen.Keybindings) {
+	d.SearchInputField.SetKeybindings(keybindings)
+	d.ReplaceInputField.SetKeybindings(keybindings)
+	d.DirectoryInputField.SetKeybindings(keybindings)
+	d.IncludeInputField.SetKeybindings(keybindings)
+	d.ExcludeInputField.SetKeybindings(keybindings)
+}
@@ -0,0 +1,307 @@
+package keybindingsdialog
+
+import (
+	"dinky/internal/tui/dialog"
+	"dinky/internal/tui/scrollbar"
+	"dinky/internal/tui/table2"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// KeyBindingsDialog lists the commands and their keys, and lets the user
+// pick a new key for a command by pressing it.
+type KeyBindingsDialog struct {
+	*tview.Flex
+	app *tview.Application
+
+	messageView          *tview.TextView
+	verticalContentsFlex *tview.Flex
+	buttonsFlex          *tview.Flex
+	innerFlex            *tview.Flex
+
+	TableField        *table2.Table
+	VerticalScrollbar *scrollbar.Scrollbar
+	Buttons           []*tview.Button
+
+	ItemStyle tcell.Style
+	KeyStyle  tcell.Style
+
+	options   KeyBindingsDialogOptions
+	recording bool // Waiting for the new key of the selected item
+}
+
+type KeyBindingsDialogOptions struct {
+	Items    []Item
+	OnCancel func()
+	// OnKey is called with the key pressed for the item.
+	OnKey func(id string, event *tcell.EventKey)
+	// OnRemove takes every key off the item.
+	OnRemove func(id string)
+	// OnReset puts the item's keys back to the defaults.
+	OnReset func(id string)
+}
+
+// Item is one command in the list.
+type Item struct {
+	ID    string
+	Title string
+	Keys  string
+}
+
+const KeyBindingsDialogWidth = 76
+const keyBindingsDialogHeight = 24
+
+const helpMessage = "Enter to change the key of a command"
+
+const (
+	buttonChange = iota
+	buttonRemove
+	buttonReset
+	buttonClose
+)
+
+func NewKeyBindingsDialog(app *tview.Application) *KeyBindingsDialog {
+	topLayout := tview.NewFlex()
+	topLayout.AddItem(nil, 0, 1, false)
+
+	innerFlex := tview.NewFlex()
+	innerFlex.AddItem(nil, 0, 1, false)
+
+	verticalContentsFlex := tview.NewFlex()
+
+	verticalContentsFlex.Box = tview.NewBox() // Nasty hack to clear the `dontClear` flag inside Box.
+	verticalContentsFlex.Box.Primitive = topLayout
+
+	verticalContentsFlex.SetDirection(tview.FlexRow)
+	verticalContentsFlex.SetBorderPadding(1, 1, 1, 1)
+	verticalContentsFlex.SetBorder(true)
+	verticalContentsFlex.SetTitleAlign(tview.AlignLeft)
+	verticalContentsFlex.SetTitle("Key Bindings")
+
+	messageView := tview.NewTextView()
+	messageView.SetDynamicColors(true)
+	verticalContentsFlex.AddItem(messageView, 1, 0, false)
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	tableField := table2.NewTable()
+	tableField.SetSelectable(true, false)
+
+	tableFlex := tview.NewFlex()
+	tableFlex.SetDirection(tview.FlexColumn)
+	tableFlex.SetBorder(false)
+	tableFlex.AddItem(tableField, 0, 1, false)
+
+	verticalScrollbar := scrollbar.NewScrollbar()
+	tableFlex.AddItem(verticalScrollbar, 1, 0, false)
+
+	verticalContentsFlex.AddItem(tableFlex, 0, 1, false)
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	buttonsFlex := tview.NewFlex()
+	buttonsFlex.SetDirection(tview.FlexColumn)
+	buttonsFlex.SetBorder(false)
+	verticalContentsFlex.AddItem(buttonsFlex, 1, 0, false)
+
+	innerFlex.AddItem(verticalContentsFlex, KeyBindingsDialogWidth, 0, true)
+	innerFlex.AddItem(nil, 0, 1, false)
+	innerFlex.SetDirection(tview.FlexColumn)
+
+	topLayout.AddItem(innerFlex, keyBindingsDialogHeight, 0, true)
+	topLayout.AddItem(nil, 0, 1, false)
+	topLayout.SetDirection(tview.FlexRow)
+
+	d := &KeyBindingsDialog{
+		Flex:                 topLayout,
+		app:                  app,
+		messageView:          messageView,
+		verticalContentsFlex: verticalContentsFlex,
+		buttonsFlex:          buttonsFlex,
+		innerFlex:            innerFlex,
+		TableField:           tableField,
+		VerticalScrollbar:    verticalScrollbar,
+		ItemStyle:            tcell.StyleDefault.Foreground(tview.Styles.PrimaryTextColor),
+		KeyStyle:             tcell.StyleDefault.Foreground(tview.Styles.SecondaryTextColor),
+	}
+
+	verticalScrollbar.Track.SetBeforeDrawFunc(func(_ tcell.Screen) {
+		row, _ := tableField.GetOffset()
+		verticalScrollbar.Track.SetMax(tableField.GetRowCount() - 1)
+		_, _, _, height := tableField.GetInnerRect()
+		verticalScrollbar.Track.SetThumbSize(height)
+		verticalScrollbar.Track.SetPosition(row)
+	})
+	verticalScrollbar.SetChangedFunc(func(position int) {
+		_, column := tableField.GetOffset()
+		tableField.SetOffset(position, column)
+	})
+
+	tableField.SetDoubleClickFunc(func(row int, _ int) {
+		d.startRecording()
+	})
+	return d
+}
+
+func (d *KeyBindingsDialog) Open(options KeyBindingsDialogOptions) {
+	d.options = options
+	d.recording = false
+	d.messageView.SetText(helpMessage)
+
+	d.Buttons = dialog.CreateButtonsRow(d.buttonsFlex, []string{"Change Key", "Remove Key", "Reset", "Close"},
+		d.handleButton)
+	for _, btn := range d.Buttons {
+		btn.SetInputCapture(d.inputFilter)
+	}
+	d.TableField.SetInputCapture(d.inputFilter)
+
+	d.SetItems(options.Items)
+	d.TableField.Select(0, 0)
+	d.TableField.ScrollToBeginning()
+	d.app.SetFocus(d.TableField)
+}
+
+func (d *KeyBindingsDialog) Close() {
+	d.recording = false
+}
+
+// SetItems refills the list, keeping the selection where it was.
+func (d *KeyBindingsDialog) SetItems(items []Item) {
+	d.options.Items = items
+	d.TableField.Clear()
+	for row, item := range items {
+		d.TableField.SetCell(row, 0, &table2.TableCell{
+			Text:  tview.Escape(item.Title) + " ",
+			Style: d.ItemStyle,
+		})
+		d.TableField.SetCell(row, 1, &table2.TableCell{
+			Text:  tview.Escape(item.Keys),
+			Style: d.KeyStyle,
+		})
+	}
+}
+
+// SetMessage shows a line of text above the list. It may hold tview color
+// tags.
+func (d *KeyBindingsDialog) SetMessage(message string) {
+	d.messageView.SetText(message)
+}
+
+func (d *KeyBindingsDialog) selectedItem() (Item, bool) {
+	row, _ := d.TableField.GetSelection()
+	if row < 0 || row >= len(d.options.Items) {
+		return Item{}, false
+	}
+	return d.options.Items[row], true
+}
+
+func (d *KeyBindingsDialog) startRecording() {
+	item, ok := d.selectedItem()
+	if !ok {
+		return
+	}
+	d.recording = true
+	d.messageView.SetText("Press the new key for " + tview.Escape(item.Title) + ", or Esc to cancel")
+	d.app.SetFocus(d.TableField)
+}
+
+func (d *KeyBindingsDialog) handleButton(button string, index int) {
+	item, ok := d.selectedItem()
+	switch index {
+	case buttonChange:
+		d.startRecording()
+	case buttonRemove:
+		if ok && d.options.OnRemove != nil {
+			d.options.OnRemove(item.ID)
+		}
+	case buttonReset:
+		if ok && d.options.OnReset != nil {
+			d.options.OnReset(item.ID)
+		}
+	case buttonClose:
+		if d.options.OnCancel != nil {
+			d.options.OnCancel()
+		}
+	}
+}
+
+func (d *KeyBindingsDialog) inputFilter(event *tcell.EventKey) *tcell.EventKey {
+	if d.recording {
+		d.recording = false
+		if event.Key() == tcell.KeyEscape {
+			d.messageView.SetText(helpMessage)
+			return nil
+		}
+		if item, ok := d.selectedItem(); ok && d.options.OnKey != nil {
+			d.options.OnKey(item.ID, event)
+		}
+		return nil
+	}
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if d.options.OnCancel != nil {
+			d.options.OnCancel()
+		}
+		return nil
+
+	case tcell.KeyLeft:
+		for i := 1; i < len(d.Buttons); i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i-1])
+				return nil
+			}
+		}
+
+	case tcell.KeyRight:
+		for i := 0; i < len(d.Buttons)-1; i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i+1])
+				return nil
+			}
+		}
+
+	case tcell.KeyTab:
+		if event.Modifiers() == tcell.ModNone {
+			d.handleTabKey(1)
+		} else if event.Modifiers() == tcell.ModShift {
+			d.handleTabKey(-1)
+		}
+		return nil
+
+	case tcell.KeyBacktab:
+		d.handleTabKey(-1)
+		return nil
+
+	case tcell.KeyEnter:
+		if d.TableField.HasFocus() {
+			d.startRecording()
+			return nil
+		}
+	}
+	return event
+}
+
+func (d *KeyBindingsDialog) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return d.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		d.verticalContentsFlex.MouseHandler()(action, event, setFocus)
+		return true, nil
+	})
+}
+
+// Focus is called when this primitive receives focus.
+func (d *KeyBindingsDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.TableField)
+}
+
+func (d *KeyBindingsDialog) handleTabKey(direction int) {
+	widgets := []tview.Primitive{d.TableField}
+	for _, btn := range d.Buttons {
+		widgets = append(widgets, btn)
+	}
+	for i := 0; i < len(widgets); i++ {
+		if widgets[i].HasFocus() {
+			d.app.SetFocus(widgets[(i+direction+len(widgets))%len(widgets)])
+			return
+		}
+	}
+}
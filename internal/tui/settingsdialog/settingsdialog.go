@@ -25,9 +25,15 @@ type SettingsDialog struct {
 	ShowMatchBracketCheckbox       *tview.Checkbox
 	ShowTrailingWhitespaceCheckbox *tview.Checkbox
 	SoftWrapCheckbox               *tview.Checkbox
+	RestoreSessionCheckbox         *tview.Checkbox
+	TrimWhitespaceOnSaveCheckbox   *tview.Checkbox
+	FinalNewlineOnSaveCheckbox     *tview.Checkbox
+	BackupOnSaveCheckbox           *tview.Checkbox
 	TabCharList                    *tview.List
 	TabSizeList                    *tview.List
 	VerticalRulerInputField        *smidgeninputfield.SmidgenInputField
+	HistorySizeInputField          *smidgeninputfield.SmidgenInputField
+	KeymapDropDown                 *tview.DropDown
 
 	// Smidgen color scheme list
 	ColorSchemeTableField             *table2.Table
@@ -36,6 +42,8 @@ type SettingsDialog struct {
 	ColorSchemeTableVerticalScrollbar *scrollbar.Scrollbar
 	selectedColorScheme               string
 
+	settings settingstype.Settings // Keeps what is only editable in the settings file
+
 	colorFiles []string
 
 	colorSchemeItemTextColor               tcell.Color
@@ -122,6 +130,10 @@ func main() {
 	showMatchBracketCheckbox.SetLabel("Show Match Bracket:       ")
 	firstColumnFlex.AddItem(showMatchBracketCheckbox, 1, 0, false)
 
+	restoreSessionCheckbox := tview.NewCheckbox()
+	restoreSessionCheckbox.SetLabel("Restore Session:          ")
+	firstColumnFlex.AddItem(restoreSessionCheckbox, 1, 0, false)
+
 	// Second column of checkboxes
 	secondColumnFlex := tview.NewFlex()
 	secondColumnFlex.SetDirection(tview.FlexRow)
@@ -191,7 +203,65 @@ func main() {
 	optionsColumnFlex.AddItem(forthColumnFlex, 12, 0, false)
 	optionsColumnFlex.AddItem(fifthColumnFlex, 0, 1, false)
 
-	verticalContentsFlex.AddItem(optionsColumnFlex, 5, 0, false)
+	verticalContentsFlex.AddItem(optionsColumnFlex, 6, 0, false)
+
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	// Steps applied when saving
+	onSaveFlex := tview.NewFlex()
+	onSaveFlex.SetDirection(tview.FlexColumn)
+
+	onSaveLabel := tview.NewTextView()
+	onSaveLabel.SetText("On Save:")
+	onSaveFlex.AddItem(onSaveLabel, 10, 0, false)
+
+	trimWhitespaceOnSaveCheckbox := tview.NewCheckbox()
+	trimWhitespaceOnSaveCheckbox.SetLabel("Trim Whitespace: ")
+	onSaveFlex.AddItem(trimWhitespaceOnSaveCheckbox, 21, 0, false)
+
+	finalNewlineOnSaveCheckbox := tview.NewCheckbox()
+	finalNewlineOnSaveCheckbox.SetLabel("Final Newline: ")
+	onSaveFlex.AddItem(finalNewlineOnSaveCheckbox, 19, 0, false)
+
+	backupOnSaveCheckbox := tview.NewCheckbox()
+	backupOnSaveCheckbox.SetLabel("Keep Backup: ")
+	onSaveFlex.AddItem(backupOnSaveCheckbox, 0, 1, false)
+
+	verticalContentsFlex.AddItem(onSaveFlex, 1, 0, false)
+
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	keymapFlex := tview.NewFlex()
+	keymapFlex.SetDirection(tview.FlexColumn)
+
+	keymapLabel := tview.NewTextView()
+	keymapLabel.SetText("Keymap:")
+	keymapFlex.AddItem(keymapLabel, 10, 0, false)
+
+	keymapDropDown := tview.NewDropDown()
+	for _, keymap := range settingstype.Keymaps {
+		keymapDropDown.AddOption(" "+keymap.Title+" ", nil)
+	}
+	keymapFlex.AddItem(keymapDropDown, 14, 0, false)
+
+	keymapHint := tview.NewTextView()
+	keymapHint.SetText(" keybindings.json goes on top")
+	keymapFlex.AddItem(keymapHint, 0, 1, false)
+
+	historySizeLabel := tview.NewTextView()
+	historySizeLabel.SetText("History Size: ")
+	keymapFlex.AddItem(historySizeLabel, 14, 0, false)
+
+	historySizeInputField := smidgeninputfield.NewSmidgenInputField(app)
+	keymapFlex.AddItem(historySizeInputField, 5, 0, false)
+	historySizeInputField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if numericInputFilter(event) {
+			return event
+		}
+		return nil
+	})
+
+	verticalContentsFlex.AddItem(keymapFlex, 1, 0, false)
 
 	verticalContentsFlex.AddItem(nil, 1, 0, false)
 
@@ -210,7 +280,7 @@ func main() {
 	innerFlex := tview.NewFlex()
 	innerFlex.SetDirection(tview.FlexRow)
 	innerFlex.AddItem(nil, 0, 1, false)
-	innerFlex.AddItem(verticalContentsFlex, 23, 0, true)
+	innerFlex.AddItem(verticalContentsFlex, 28, 0, true)
 	innerFlex.AddItem(nil, 0, 1, false)
 
 	topLayout := tview.NewFlex()
@@ -228,9 +298,15 @@ func main() {
 		ShowMatchBracketCheckbox:       showMatchBracketCheckbox,
 		ShowTrailingWhitespaceCheckbox: showTrailingWhitespaceCheckbox,
 		SoftWrapCheckbox:               softWrapCheckbox,
+		RestoreSessionCheckbox:         restoreSessionCheckbox,
+		TrimWhitespaceOnSaveCheckbox:   trimWhitespaceOnSaveCheckbox,
+		FinalNewlineOnSaveCheckbox:     finalNewlineOnSaveCheckbox,
+		BackupOnSaveCheckbox:           backupOnSaveCheckbox,
 		TabCharList:                    tabCharList,
 		TabSizeList:                    tabSizeList,
 		VerticalRulerInputField:        verticalRulerInputField,
+		KeymapDropDown:                 keymapDropDown,
+		HistorySizeInputField:          historySizeInputField,
 
 		ColorSchemeTableField:             colorSchemeTableField,
 		ColorSchemeTableFlex:              colorSchemeTableFlex,
@@ -291,6 +367,7 @@ func (sd *SettingsDialog) SetCloseFunc(closeFunc func()) {
 }
 
 func (sd *SettingsDialog) SetSettings(settings settingstype.Settings) {
+	sd.settings = settings
 	for rowIndex, item := range sd.colorFiles {
 		if item == settings.ColorScheme {
 			sd.ColorSchemeTableField.Select(rowIndex, 0)
@@ -305,6 +382,10 @@ func (sd *SettingsDialog) SetSettings(settings settingstype.Settings) {
 	sd.ShowTrailingWhitespaceCheckbox.SetChecked(settings.ShowTrailingWhitespace)
 	sd.ShowMatchBracketCheckbox.SetChecked(settings.ShowMatchBracket)
 	sd.SoftWrapCheckbox.SetChecked(settings.SoftWrap)
+	sd.RestoreSessionCheckbox.SetChecked(settings.RestoreSession)
+	sd.TrimWhitespaceOnSaveCheckbox.SetChecked(settings.TrimTrailingWhitespaceOnSave)
+	sd.FinalNewlineOnSaveCheckbox.SetChecked(settings.EnsureFinalNewlineOnSave)
+	sd.BackupOnSaveCheckbox.SetChecked(settings.BackupOnSave)
 	if settings.TabCharacter == "tab" {
 		sd.TabCharList.SetCurrentItem(0)
 	} else {
@@ -324,16 +405,28 @@ func (sd *SettingsDialog) SetSettings(settings settingstype.Settings) {
 	}
 
 	sd.VerticalRulerInputField.SetText(strconv.Itoa(int(settings.VerticalRuler)))
+	sd.HistorySizeInputField.SetText(strconv.Itoa(settings.HistorySize))
+
+	sd.KeymapDropDown.SetCurrentOption(0)
+	for i, keymap := range settingstype.Keymaps {
+		if keymap.Name == settings.Keymap {
+			sd.KeymapDropDown.SetCurrentOption(i)
+		}
+	}
 }
 
 func (sd *SettingsDialog) getSettings() settingstype.Settings {
-	newSettings := settingstype.DefaultSettings()
+	newSettings := sd.settings
 	newSettings.ColorScheme = sd.selectedColorScheme
 	newSettings.ShowLineNumbers = sd.ShowLineNumbersCheckbox.IsChecked()
 	newSettings.ShowWhitespace = sd.ShowWhitespaceCheckbox.IsChecked()
 	newSettings.ShowTrailingWhitespace = sd.ShowTrailingWhitespaceCheckbox.IsChecked()
 	newSettings.ShowMatchBracket = sd.ShowMatchBracketCheckbox.IsChecked()
 	newSettings.SoftWrap = sd.SoftWrapCheckbox.IsChecked()
+	newSettings.RestoreSession = sd.RestoreSessionCheckbox.IsChecked()
+	newSettings.TrimTrailingWhitespaceOnSave = sd.TrimWhitespaceOnSaveCheckbox.IsChecked()
+	newSettings.EnsureFinalNewlineOnSave = sd.FinalNewlineOnSaveCheckbox.IsChecked()
+	newSettings.BackupOnSave = sd.BackupOnSaveCheckbox.IsChecked()
 	tabCharIndex := sd.TabCharList.GetCurrentItem()
 	if tabCharIndex == 0 {
 		newSettings.TabCharacter = "tab"
@@ -357,6 +450,14 @@ func (sd *SettingsDialog) getSettings() settingstype.Settings {
 
 	value, _ := strconv.Atoi(sd.VerticalRulerInputField.GetText())
 	newSettings.VerticalRuler = float64(value)
+
+	if historySize, err := strconv.Atoi(sd.HistorySizeInputField.GetText()); err == nil {
+		newSettings.HistorySize = historySize
+	}
+
+	if index, _ := sd.KeymapDropDown.GetCurrentOption(); index >= 0 {
+		newSettings.Keymap = settingstype.Keymaps[index].Name
+	}
 	return newSettings
 }
 
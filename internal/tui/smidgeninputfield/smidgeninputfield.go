@@ -1,19 +1,28 @@
 package smidgeninputfield
 
 import (
+	"slices"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/sedwards2009/smidgen"
 )
 
+const maxHistoryListHeight = 10
+
 type SmidgenInputField struct {
 	*smidgen.View
 	userText       string
-	history        []string
-	historyPointer int
+	history        []string // Most recent first
+	historyPointer int      // -1 is the text the user typed
+
+	// HistoryList drops down with Alt+Down to pick or delete history entries.
+	HistoryList   *tview.List
+	isHistoryOpen bool
 
-	done    func(tcell.Key)
-	changed func(text string)
+	done           func(tcell.Key)
+	changed        func(text string)
+	historyChanged func(history []string)
 }
 
 func NewSmidgenInputField(app *tview.Application) *SmidgenInputField {
@@ -22,16 +31,47 @@ func NewSmidgenInputField(app *tview.Application) *SmidgenInputField {
 	buffer.Settings["ruler"] = false
 	buffer.Settings["hidecursoronblur"] = true
 
+	historyList := tview.NewList()
+	historyList.ShowSecondaryText(false)
+	historyList.SetHighlightFullLine(true)
+
 	return &SmidgenInputField{
 		View:           editor,
 		historyPointer: -1,
+		HistoryList:    historyList,
 	}
 }
 
+// SetHistory sets the entries which Up and Down step through, most recent
+// first.
 func (f *SmidgenInputField) SetHistory(historyText []string) {
-	f.history = make([]string, len(historyText))
-	copy(f.history, historyText)
-	f.historyPointer = len(historyText)
+	f.history = slices.Clone(historyText)
+	f.historyPointer = -1
+	if f.isHistoryOpen {
+		f.fillHistoryList()
+	}
+}
+
+// SetHistoryChangedFunc sets a handler which is called when the user
+// deletes an entry from the history list.
+func (f *SmidgenInputField) SetHistoryChangedFunc(handler func(history []string)) *SmidgenInputField {
+	f.historyChanged = handler
+	return f
+}
+
+// AddToHistory puts an entry at the front of a history list, removing any
+// older copy of it and dropping the oldest entries past maxSize.
+func AddToHistory(history []string, entry string, maxSize int) []string {
+	if entry == "" {
+		return history
+	}
+	newHistory := []string{entry}
+	for _, item := range history {
+		if item != entry {
+			newHistory = append(newHistory, item)
+		}
+	}
+	return newHistory[:min(len(newHistory), max(maxSize, 0))]
 }
 
 func (f *SmidgenInputField) SetKeybindings(keybindings smidgen.Keybindings) {
@@ -62,6 +102,12 @@ func (f *SmidgenInputField) internalSetText(text string) {
 
 func (f *SmidgenInputField) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
 	return f.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if f.isHistoryOpen {
+			if f.handleHistoryListKey(event, setFocus) {
+				return
+			}
+		}
+
 		switch event.Key() {
 		case tcell.KeyEnter, tcell.KeyEscape, tcell.KeyTab, tcell.KeyBacktab:
 			if f.done != nil {
@@ -69,8 +115,8 @@ func (f *SmidgenInputField) InputHandler() func(event *tcell.EventKey, setFocus
 			}
 			return
 		case tcell.KeyUp:
-			if len(f.history) != 0 && f.historyPointer > 0 {
-				f.historyPointer--
+			if f.historyPointer < len(f.history)-1 {
+				f.historyPointer++
 				f.internalSetText(f.history[f.historyPointer])
 				if f.changed != nil {
 					f.changed(f.GetText())
@@ -78,14 +124,16 @@ func (f *SmidgenInputField) InputHandler() func(event *tcell.EventKey, setFocus
 				return
 			}
 		case tcell.KeyDown:
-			if len(f.history) != 0 {
-				if f.historyPointer < len(f.history)-1 {
-					f.historyPointer++
+			if event.Modifiers()&tcell.ModAlt != 0 {
+				f.openHistoryList()
+				return
+			}
+			if f.historyPointer >= 0 {
+				f.historyPointer--
+				if f.historyPointer >= 0 {
 					f.internalSetText(f.history[f.historyPointer])
 				} else {
 					f.internalSetText(f.userText)
-					f.historyPointer = len(f.history)
-					// Set to one past the end of history, so pressing up goes to the most recent entry
 				}
 				if f.changed != nil {
 					f.changed(f.GetText())
@@ -99,10 +147,7 @@ func (f *SmidgenInputField) InputHandler() func(event *tcell.EventKey, setFocus
 		if f.View.Buffer().Modified() {
 			f.View.Buffer().ClearModified()
 			f.userText = f.GetText()
-			if len(f.history) != 0 {
-				f.historyPointer = len(f.history)
-				// Reset history pointer to end of history, so pressing up goes to the most recent entry
-			}
+			f.historyPointer = -1 // Pressing up goes to the most recent entry again
 			if f.changed != nil {
 				f.changed(f.GetText())
 			}
@@ -119,3 +164,87 @@ func (f *SmidgenInputField) SetChangedFunc(handler func(text string)) *SmidgenIn
 	f.changed = handler
 	return f
 }
+
+func (f *SmidgenInputField) openHistoryList() {
+	if len(f.history) == 0 {
+		return
+	}
+	f.isHistoryOpen = true
+	f.fillHistoryList()
+}
+
+func (f *SmidgenInputField) closeHistoryList() {
+	f.isHistoryOpen = false
+}
+
+func (f *SmidgenInputField) fillHistoryList() {
+	current := f.HistoryList.GetCurrentItem()
+	f.HistoryList.Clear()
+	for _, entry := range f.history {
+		f.HistoryList.AddItem(tview.Escape(entry), "", 0, nil)
+	}
+	if len(f.history) == 0 {
+		f.closeHistoryList()
+		return
+	}
+	f.HistoryList.SetCurrentItem(min(current, len(f.history)-1))
+}
+
+// handleHistoryListKey handles the keys for the history list while it is
+// open. It returns false for keys which the field itself should handle.
+func (f *SmidgenInputField) handleHistoryListKey(event *tcell.EventKey, setFocus func(p tview.Primitive)) bool {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		f.closeHistoryList()
+		return true
+
+	case tcell.KeyEnter:
+		entry := f.history[f.HistoryList.GetCurrentItem()]
+		f.closeHistoryList()
+		f.SetText(entry)
+		if f.changed != nil {
+			f.changed(f.GetText())
+		}
+		return true
+
+	case tcell.KeyDelete:
+		index := f.HistoryList.GetCurrentItem()
+		f.history = slices.Delete(f.history, index, index+1)
+		f.historyPointer = -1
+		f.fillHistoryList()
+		if f.historyChanged != nil {
+			f.historyChanged(slices.Clone(f.history))
+		}
+		return true
+
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn, tcell.KeyHome, tcell.KeyEnd:
+		f.HistoryList.InputHandler()(event, setFocus)
+		return true
+	}
+	f.closeHistoryList()
+	return false
+}
+
+func (f *SmidgenInputField) Blur() {
+	f.closeHistoryList()
+	f.View.Blur()
+}
+
+// Draw draws the field and the history list when it is open. The list goes
+// below the field, or above it when there isn't room.
+func (f *SmidgenInputField) Draw(screen tcell.Screen) {
+	f.View.Draw(screen)
+	if !f.isHistoryOpen {
+		return
+	}
+
+	x, y, width, _ := f.GetRect()
+	_, screenHeight := screen.Size()
+	height := min(len(f.history), maxHistoryListHeight)
+	listY := y + 1
+	if listY+height > screenHeight {
+		listY = max(y-height, 0)
+	}
+	f.HistoryList.SetRect(x, listY, width, height)
+	f.HistoryList.Draw(screen)
+}
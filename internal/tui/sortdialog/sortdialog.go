@@ -0,0 +1,315 @@
+package sortdialog
+
+import (
+	"dinky/internal/tui/dialog"
+	"dinky/internal/tui/smidgeninputfield"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sedwards2009/smidgen"
+)
+
+type SortDialog struct {
+	*tview.Flex
+	app *tview.Application
+
+	verticalContentsFlex *tview.Flex
+	buttonsFlex          *tview.Flex
+
+	OrderDropDown            *tview.DropDown
+	CompareDropDown          *tview.DropDown
+	OutputDropDown           *tview.DropDown
+	FieldInputField          *smidgeninputfield.SmidgenInputField
+	DelimiterInputField      *smidgeninputfield.SmidgenInputField
+	IgnoreCaseCheckbox       *tview.Checkbox
+	RemoveDuplicatesCheckbox *tview.Checkbox
+
+	Buttons []*tview.Button
+	options SortDialogOptions
+}
+
+type SortDialogOptions struct {
+	OnCancel func()
+	OnAccept func(choices Choices, buttonIndex int)
+}
+
+type Order int
+
+const (
+	Ascending Order = iota
+	Descending
+	Shuffle
+)
+
+type Compare int
+
+const (
+	CompareText    Compare = iota
+	CompareNatural         // Runs of digits compare as numbers, so file9 comes before file10
+	CompareNumeric
+)
+
+type Output int
+
+const (
+	OutputSorted Output = iota
+	OutputUnique        // Only the lines which appear once
+	OutputCounts        // Each different line once, after how many times it appears
+)
+
+// Choices is what was picked in the dialog.
+type Choices struct {
+	Order            Order
+	Compare          Compare
+	Output           Output
+	Field            string // Which field to sort by, or empty for the whole line
+	Delimiter        string // What separates fields, or empty for white space
+	IgnoreCase       bool
+	RemoveDuplicates bool
+}
+
+const SortDialogWidth = 62
+const sortDialogHeight = 16
+
+func NewSortDialog(app *tview.Application) *SortDialog {
+	topLayout := tview.NewFlex()
+
+	topLayout.AddItem(nil, 0, 1, false)
+
+	innerFlex := tview.NewFlex()
+	innerFlex.AddItem(nil, 0, 1, false)
+
+	verticalContentsFlex := tview.NewFlex()
+
+	verticalContentsFlex.Box = tview.NewBox() // Nasty hack to clear the `dontClear` flag inside Box.
+	verticalContentsFlex.Box.Primitive = topLayout
+
+	verticalContentsFlex.SetDirection(tview.FlexRow)
+	verticalContentsFlex.SetBorderPadding(1, 1, 1, 1)
+	verticalContentsFlex.SetBorder(true)
+	verticalContentsFlex.SetTitleAlign(tview.AlignLeft)
+	verticalContentsFlex.SetTitle("Sort Lines")
+
+	addRow := func(label string, item tview.Primitive, width int) *tview.Flex {
+		rowFlex := tview.NewFlex()
+		rowFlex.SetDirection(tview.FlexColumn)
+		labelView := tview.NewTextView()
+		labelView.SetText(label)
+		rowFlex.AddItem(labelView, 12, 0, false)
+		rowFlex.AddItem(item, width, 0, false)
+		verticalContentsFlex.AddItem(rowFlex, 1, 0, false)
+		return rowFlex
+	}
+	addDropDown := func(label string, options []string) (*tview.DropDown, *tview.Flex) {
+		dropDown := tview.NewDropDown()
+		for _, option := range options {
+			dropDown.AddOption(" "+option+" ", nil)
+		}
+		dropDown.SetCurrentOption(0)
+		return dropDown, addRow(label, dropDown, 20)
+	}
+
+	orderDropDown, _ := addDropDown("Order:", []string{"Ascending", "Descending", "Shuffle"})
+	compareDropDown, _ := addDropDown("Compare:", []string{"Text", "Natural", "Numeric"})
+	outputDropDown, _ := addDropDown("Output:", []string{"Sorted lines", "Unique lines", "Count duplicates"})
+
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	fieldInputField := smidgeninputfield.NewSmidgenInputField(app)
+	fieldFlex := addRow("Field:", fieldInputField, 6)
+	delimiterLabel := tview.NewTextView()
+	delimiterLabel.SetText("   Delimiter: ")
+	fieldFlex.AddItem(delimiterLabel, 14, 0, false)
+	delimiterInputField := smidgeninputfield.NewSmidgenInputField(app)
+	fieldFlex.AddItem(delimiterInputField, 6, 0, false)
+	fieldFlex.AddItem(nil, 0, 1, false)
+
+	checkboxFlex := tview.NewFlex()
+	checkboxFlex.SetDirection(tview.FlexColumn)
+	checkboxFlex.AddItem(nil, 12, 0, false)
+	ignoreCaseCheckbox := tview.NewCheckbox()
+	ignoreCaseCheckbox.SetLabel("Ignore Case: ")
+	checkboxFlex.AddItem(ignoreCaseCheckbox, 20, 0, false)
+	removeDuplicatesCheckbox := tview.NewCheckbox()
+	removeDuplicatesCheckbox.SetLabel("Remove Duplicates: ")
+	checkboxFlex.AddItem(removeDuplicatesCheckbox, 0, 1, false)
+	verticalContentsFlex.AddItem(checkboxFlex, 1, 0, false)
+
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	explanationLabel := tview.NewTextView()
+	explanationLabel.SetText("Fields count from 1 and are split at white space if there\nis no delimiter. Leave Field empty to use the whole line.\nShuffle always puts the same lines in the same order.")
+	verticalContentsFlex.AddItem(explanationLabel, 3, 0, false)
+	verticalContentsFlex.AddItem(nil, 1, 0, false)
+
+	buttonsFlex := tview.NewFlex()
+	buttonsFlex.SetDirection(tview.FlexColumn)
+	buttonsFlex.SetBorder(false)
+	verticalContentsFlex.AddItem(buttonsFlex, 1, 0, false)
+
+	innerFlex.AddItem(verticalContentsFlex, SortDialogWidth, 0, true)
+	innerFlex.AddItem(nil, 0, 1, false)
+	innerFlex.SetDirection(tview.FlexColumn)
+
+	topLayout.AddItem(innerFlex, sortDialogHeight, 0, true)
+	topLayout.AddItem(nil, 0, 1, false)
+	topLayout.SetDirection(tview.FlexRow)
+
+	return &SortDialog{
+		Flex:                     topLayout,
+		app:                      app,
+		verticalContentsFlex:     verticalContentsFlex,
+		buttonsFlex:              buttonsFlex,
+		OrderDropDown:            orderDropDown,
+		CompareDropDown:          compareDropDown,
+		OutputDropDown:           outputDropDown,
+		FieldInputField:          fieldInputField,
+		DelimiterInputField:      delimiterInputField,
+		IgnoreCaseCheckbox:       ignoreCaseCheckbox,
+		RemoveDuplicatesCheckbox: removeDuplicatesCheckbox,
+	}
+}
+
+func (d *SortDialog) Open(options SortDialogOptions) {
+	d.options = options
+
+	onButtonClick := func(button string, index int) {
+		d.options.OnAccept(d.Choices(), index)
+	}
+
+	d.Buttons = dialog.CreateButtonsRow(d.buttonsFlex, []string{"Sort", "Cancel"}, onButtonClick)
+	for _, btn := range d.Buttons {
+		btn.SetInputCapture(d.inputFilter)
+	}
+	d.OrderDropDown.SetInputCapture(d.inputFilter)
+	d.CompareDropDown.SetInputCapture(d.inputFilter)
+	d.OutputDropDown.SetInputCapture(d.inputFilter)
+	d.IgnoreCaseCheckbox.SetInputCapture(d.inputFilter)
+	d.RemoveDuplicatesCheckbox.SetInputCapture(d.inputFilter)
+	d.FieldInputField.SetInputCapture(d.inputFilter)
+	d.DelimiterInputField.SetInputCapture(d.inputFilter)
+
+	d.app.SetFocus(d.OrderDropDown)
+}
+
+func (d *SortDialog) Close() {
+}
+
+// Choices returns what is currently picked.
+func (d *SortDialog) Choices() Choices {
+	order, _ := d.OrderDropDown.GetCurrentOption()
+	compare, _ := d.CompareDropDown.GetCurrentOption()
+	output, _ := d.OutputDropDown.GetCurrentOption()
+	return Choices{
+		Order:            Order(order),
+		Compare:          Compare(compare),
+		Output:           Output(output),
+		Field:            d.FieldInputField.GetText(),
+		Delimiter:        d.DelimiterInputField.GetText(),
+		IgnoreCase:       d.IgnoreCaseCheckbox.IsChecked(),
+		RemoveDuplicates: d.RemoveDuplicatesCheckbox.IsChecked(),
+	}
+}
+
+// SetChoices fills in the dialog, usually with what was picked last time.
+func (d *SortDialog) SetChoices(choices Choices) {
+	d.OrderDropDown.SetCurrentOption(int(choices.Order))
+	d.CompareDropDown.SetCurrentOption(int(choices.Compare))
+	d.OutputDropDown.SetCurrentOption(int(choices.Output))
+	d.FieldInputField.SetText(choices.Field)
+	d.DelimiterInputField.SetText(choices.Delimiter)
+	d.IgnoreCaseCheckbox.SetChecked(choices.IgnoreCase)
+	d.RemoveDuplicatesCheckbox.SetChecked(choices.RemoveDuplicates)
+}
+
+func (d *SortDialog) dropDownIsOpen() bool {
+	return d.OrderDropDown.IsOpen() || d.CompareDropDown.IsOpen() || d.OutputDropDown.IsOpen()
+}
+
+func (d *SortDialog) inputFilter(event *tcell.EventKey) *tcell.EventKey {
+	// An open drop down list has the keys to itself.
+	if d.dropDownIsOpen() {
+		return event
+	}
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		if d.options.OnCancel != nil {
+			d.options.OnCancel()
+		}
+		return nil
+
+	case tcell.KeyLeft:
+		for i := 1; i < len(d.Buttons); i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i-1])
+				return nil
+			}
+		}
+
+	case tcell.KeyRight:
+		for i := 0; i < len(d.Buttons)-1; i++ {
+			if d.Buttons[i].HasFocus() {
+				d.app.SetFocus(d.Buttons[i+1])
+				return nil
+			}
+		}
+
+	case tcell.KeyTab:
+		if event.Modifiers() == tcell.ModNone {
+			d.handleTabKey(1)
+		} else if event.Modifiers() == tcell.ModShift {
+			d.handleTabKey(-1)
+		}
+		return nil
+
+	case tcell.KeyBacktab:
+		d.handleTabKey(-1)
+		return nil
+
+	case tcell.KeyEnter:
+		if d.FieldInputField.HasFocus() || d.DelimiterInputField.HasFocus() {
+			if d.options.OnAccept != nil {
+				d.options.OnAccept(d.Choices(), -1)
+			}
+			return nil
+		}
+	}
+	return event
+}
+
+func (d *SortDialog) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return d.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		d.verticalContentsFlex.MouseHandler()(action, event, setFocus)
+		return true, nil
+	})
+}
+
+// Focus is called when this primitive receives focus.
+func (d *SortDialog) Focus(delegate func(p tview.Primitive)) {
+	delegate(d.OrderDropDown)
+}
+
+func (d *SortDialog) widgets() []tview.Primitive {
+	widgets := []tview.Primitive{d.OrderDropDown, d.CompareDropDown, d.OutputDropDown, d.FieldInputField,
+		d.DelimiterInputField, d.IgnoreCaseCheckbox, d.RemoveDuplicatesCheckbox}
+	for _, btn := range d.Buttons {
+		widgets = append(widgets, btn)
+	}
+	return widgets
+}
+
+func (d *SortDialog) handleTabKey(direction int) {
+	widgets := d.widgets()
+	for i := 0; i < len(widgets); i++ {
+		if widgets[i].HasFocus() {
+			d.app.SetFocus(widgets[(i+direction+len(widgets))%len(widgets)])
+			return
+		}
+	}
+}
+
+func (d *SortDialog) SetSmidgenKeybindings(keybindings smidgen.Keybindings) {
+	d.FieldInputField.SetKeybindings(keybindings)
+	d.DelimiterInputField.SetKeybindings(keybindings)
+}
@@ -29,11 +29,13 @@ type StatusBar struct {
 	Line            int
 	Col             int
 	LineEndings     string
+	Encoding        string
 	TabSize         int
 	message         string
 	errorMessage    string
 	warningMessage  string
 	IsOverwriteMode bool
+	PendingKeys     string                     // The first keys of a chord, while waiting for the rest
 	UpdateHook      func(statusBar *StatusBar) // Hook for updating the status bar
 }
 
@@ -101,6 +103,10 @@ func (statusBar *StatusBar) Draw(screen tcell.Screen) {
 		leftMessage = statusBar.errorMessage
 		style = statusBar.ErrorStyle
 	}
+	if statusBar.PendingKeys != "" {
+		leftMessage = statusBar.PendingKeys + " was pressed. Waiting for the next key\u2026"
+		style = statusBar.MessageStyle
+	}
 
 	utils.DrawHorizontalLine(screen, x, y, width, style, ' ')
 	utils.DrawText(screen, x, y, leftMessage, style)
@@ -114,7 +120,8 @@ func (statusBar *StatusBar) Draw(screen tcell.Screen) {
 	for i := 0; i < padding; i++ {
 		cursorMessage += " "
 	}
-	rightMessage := fmt.Sprintf("%s  Tab Size: %d  %s  F12: Menu", cursorMessage, statusBar.TabSize, statusBar.LineEndings)
+	rightMessage := fmt.Sprintf("%s  Tab Size: %d  %s  %s  F12: Menu", cursorMessage, statusBar.TabSize, statusBar.LineEndings,
+		statusBar.Encoding)
 	rx := width - runewidth.StringWidth(rightMessage) - 1
 	utils.DrawText(screen, rx, y, rightMessage, style)
 }
@@ -1,15 +1,19 @@
 package style
 
 import (
+	"dinky/internal/tui/commandpalette"
 	"dinky/internal/tui/dialog"
 	"dinky/internal/tui/filedialog"
 	"dinky/internal/tui/filelist"
 	"dinky/internal/tui/filterdialog"
 	"dinky/internal/tui/findbar"
+	"dinky/internal/tui/findinfilesdialog"
+	"dinky/internal/tui/keybindingsdialog"
 	"dinky/internal/tui/menu"
 	"dinky/internal/tui/scrollbar"
 	"dinky/internal/tui/settingsdialog"
 	"dinky/internal/tui/smidgeninputfield"
+	"dinky/internal/tui/sortdialog"
 	"dinky/internal/tui/stylecolor"
 	"dinky/internal/tui/tabbar"
 	"dinky/internal/tui/table2"
@@ -151,6 +155,8 @@ func StyleFileDialog(fileDialog *filedialog.FileDialog) {
 
 func StyleFindbar(findBar *findbar.Findbar) {
 	findBar.SetBackgroundColor(stylecolor.LightGray)
+	findBar.MatchCountStyle = tcell.StyleDefault.Foreground(stylecolor.Black).Background(stylecolor.LightGray)
+	findBar.MatchCountInvalidStyle = tcell.StyleDefault.Foreground(stylecolor.White).Background(stylecolor.Red)
 	StyleSmidgenInputField(findBar.SearchStringField)
 	StyleSmidgenInputField(findBar.ReplaceStringField)
 
@@ -168,6 +174,18 @@ func StyleFindbar(findBar *findbar.Findbar) {
 	findBar.CaseSensitiveCheckbox.SetCheckedString("[✓Aa ]")
 	findBar.CaseSensitiveCheckbox.SetUncheckedString("[ Aa ]")
 
+	StyleCheckbox(findBar.WholeWordCheckbox)
+	findBar.WholeWordCheckbox.SetCheckedString("[✓Word ]")
+	findBar.WholeWordCheckbox.SetUncheckedString("[ Word ]")
+
+	StyleCheckbox(findBar.InSelectionCheckbox)
+	findBar.InSelectionCheckbox.SetCheckedString("[✓Sel ]")
+	findBar.InSelectionCheckbox.SetUncheckedString("[ Sel ]")
+
+	StyleCheckbox(findBar.PreserveCaseCheckbox)
+	findBar.PreserveCaseCheckbox.SetCheckedString("[✓Keep Case ]")
+	findBar.PreserveCaseCheckbox.SetUncheckedString("[ Keep Case ]")
+
 	StyleCheckbox(findBar.ExpanderCheckbox)
 	findBar.ExpanderCheckbox.SetUncheckedStyle(tcell.StyleDefault.Background(stylecolor.ButtonBackgroundColor).Foreground(stylecolor.ButtonLabelColor))
 	findBar.ExpanderCheckbox.SetCheckedStyle(tcell.StyleDefault.Background(stylecolor.ButtonBackgroundColor).Foreground(stylecolor.ButtonLabelColor))
@@ -184,9 +202,14 @@ func StyleSettingsDialog(sd *settingsdialog.SettingsDialog) {
 	StyleCheckbox(sd.ShowMatchBracketCheckbox)
 	StyleCheckbox(sd.SoftWrapCheckbox)
 	StyleCheckbox(sd.ShowTrailingWhitespaceCheckbox)
+	StyleCheckbox(sd.RestoreSessionCheckbox)
+	StyleCheckbox(sd.TrimWhitespaceOnSaveCheckbox)
+	StyleCheckbox(sd.FinalNewlineOnSaveCheckbox)
+	StyleCheckbox(sd.BackupOnSaveCheckbox)
 
 	StyleList(sd.TabCharList)
 	StyleList(sd.TabSizeList)
+	StyleDropDown(sd.KeymapDropDown)
 
 	StyleTable(sd.ColorSchemeTableField)
 	sd.SetItemTextColor(stylecolor.White)
@@ -195,3 +218,52 @@ func StyleSettingsDialog(sd *settingsdialog.SettingsDialog) {
 
 	StyleScrollbar(sd.ColorSchemeTableVerticalScrollbar)
 }
+
+func StyleFindInFilesDialog(findInFilesDialog *findinfilesdialog.FindInFilesDialog) {
+	findInFilesDialog.SetBackgroundColor(stylecolor.LightGray)
+	for _, button := range findInFilesDialog.Buttons {
+		StyleButton(button)
+	}
+	StyleSmidgenInputField(findInFilesDialog.SearchInputField)
+	StyleSmidgenInputField(findInFilesDialog.ReplaceInputField)
+	StyleSmidgenInputField(findInFilesDialog.DirectoryInputField)
+	StyleSmidgenInputField(findInFilesDialog.IncludeInputField)
+	StyleSmidgenInputField(findInFilesDialog.ExcludeInputField)
+	StyleCheckbox(findInFilesDialog.CaseSensitiveCheckbox)
+	StyleCheckbox(findInFilesDialog.RegexCheckbox)
+}
+
+func StyleCommandPalette(commandPalette *commandpalette.CommandPalette) {
+	commandPalette.SetBackgroundColor(stylecolor.LightGray)
+	commandPalette.ItemStyle = tcell.StyleDefault.Foreground(stylecolor.White).Background(stylecolor.Black)
+	commandPalette.RecentItemStyle = tcell.StyleDefault.Foreground(stylecolor.Yellow).Background(stylecolor.Black)
+	commandPalette.KeyStyle = tcell.StyleDefault.Foreground(stylecolor.LightGray).Background(stylecolor.Black)
+	StyleSmidgenInputField(commandPalette.InputField)
+	StyleTable(commandPalette.TableField)
+	StyleScrollbar(commandPalette.VerticalScrollbar)
+}
+
+func StyleKeyBindingsDialog(d *keybindingsdialog.KeyBindingsDialog) {
+	d.SetBackgroundColor(stylecolor.LightGray)
+	d.ItemStyle = tcell.StyleDefault.Foreground(stylecolor.White).Background(stylecolor.Black)
+	d.KeyStyle = tcell.StyleDefault.Foreground(stylecolor.LightGray).Background(stylecolor.Black)
+	for _, button := range d.Buttons {
+		StyleButton(button)
+	}
+	StyleTable(d.TableField)
+	StyleScrollbar(d.VerticalScrollbar)
+}
+
+func StyleSortDialog(sortDialog *sortdialog.SortDialog) {
+	sortDialog.SetBackgroundColor(stylecolor.LightGray)
+	for _, button := range sortDialog.Buttons {
+		StyleButton(button)
+	}
+	StyleDropDown(sortDialog.OrderDropDown)
+	StyleDropDown(sortDialog.CompareDropDown)
+	StyleDropDown(sortDialog.OutputDropDown)
+	StyleSmidgenInputField(sortDialog.FieldInputField)
+	StyleSmidgenInputField(sortDialog.DelimiterInputField)
+	StyleCheckbox(sortDialog.IgnoreCaseCheckbox)
+	StyleCheckbox(sortDialog.RemoveDuplicatesCheckbox)
+}
@@ -102,6 +102,15 @@ func (tabBar *TabBar) RemoveTab(id string) {
 	}
 }
 
+func (tabBar *TabBar) TabTitle(id string) string {
+	for _, tab := range tabBar.tabs {
+		if tab.ID == id {
+			return tab.Title
+		}
+	}
+	return ""
+}
+
 func (tabBar *TabBar) SetTabTitle(id string, title string) {
 	for i, tab := range tabBar.tabs {
 		if tab.ID == id {
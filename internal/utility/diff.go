@@ -0,0 +1,107 @@
+package utility
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const diffContextLines = 3
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// UnifiedDiff returns a line based diff between two texts in unified diff
+// format. An empty string is returned if the texts are the same.
+func UnifiedDiff(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+
+	dmp := diffmatchpatch.New()
+	fromChars, toChars, lineArray := dmp.DiffLinesToChars(from, to)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(fromChars, toChars, false), lineArray)
+
+	lines := []diffLine{}
+	for _, d := range diffs {
+		var op byte
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			op = ' '
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range splitDiffText(d.Text) {
+			lines = append(lines, diffLine{op: op, text: text})
+		}
+	}
+
+	var result strings.Builder
+	result.WriteString("--- " + fromName + "\n")
+	result.WriteString("+++ " + toName + "\n")
+
+	i := 0
+	for i < len(lines) {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Find the end of this hunk by absorbing changes which are close together
+		hunkStart := max(0, i-diffContextLines)
+		lastChange := i
+		for j := i; j < len(lines) && j <= lastChange+2*diffContextLines; j++ {
+			if lines[j].op != ' ' {
+				lastChange = j
+			}
+		}
+		hunkEnd := min(len(lines), lastChange+diffContextLines+1)
+
+		fromLine, toLine := lineNumbersAt(lines, hunkStart)
+		fromCount, toCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				fromCount++
+			}
+			if line.op != '-' {
+				toCount++
+			}
+		}
+		result.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			result.WriteByte(line.op)
+			result.WriteString(line.text)
+			result.WriteByte('\n')
+		}
+		i = hunkEnd
+	}
+	return result.String()
+}
+
+// lineNumbersAt returns the 1-based line numbers in the 'from' and 'to' texts
+// of the diff line at the given index.
+func lineNumbersAt(lines []diffLine, index int) (fromLine int, toLine int) {
+	fromLine, toLine = 1, 1
+	for _, line := range lines[:index] {
+		if line.op != '+' {
+			fromLine++
+		}
+		if line.op != '-' {
+			toLine++
+		}
+	}
+	return fromLine, toLine
+}
+
+func splitDiffText(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return []string{""}
+	}
+	return strings.Split(text, "\n")
+}
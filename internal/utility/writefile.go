@@ -0,0 +1,128 @@
+package utility
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/google/renameio/v2"
+)
+
+const newFilePerm = 0644
+
+// WriteFile writes contents to a file while keeping the file's permissions,
+// ownership and extended attributes. Symlinks are followed and the file they
+// point to is written. The file is replaced atomically when possible, and
+// rewritten in place when the directory is not writable, the file has other
+// hard links or the new file couldn't be given the old file's owner.
+func WriteFile(filename string, contents []byte) error {
+	target, err := resolveSymlinks(filename)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return renameio.WriteFile(target, contents, newFilePerm)
+	}
+	if !info.Mode().IsRegular() {
+		return writeFileInPlace(target, contents)
+	}
+
+	stat, hasStat := info.Sys().(*syscall.Stat_t)
+	if hasStat && (stat.Nlink > 1 || !canChown(int(stat.Uid))) {
+		return writeFileInPlace(target, contents)
+	}
+
+	pendingFile, err := renameio.NewPendingFile(target, renameio.WithStaticPermissions(info.Mode().Perm()))
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return writeFileInPlace(target, contents)
+		}
+		return err
+	}
+	defer pendingFile.Cleanup()
+
+	if _, err := pendingFile.Write(contents); err != nil {
+		return err
+	}
+
+	if hasStat {
+		// This fails if the group is one we aren't in.
+		if err := pendingFile.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+			pendingFile.Cleanup()
+			return writeFileInPlace(target, contents)
+		}
+	}
+	copyXattrs(target, pendingFile.File)
+
+	// Set these last because chown clears the setuid and setgid bits.
+	if specialBits := info.Mode() & (os.ModeSetuid | os.ModeSetgid | os.ModeSticky); specialBits != 0 {
+		pendingFile.Chmod(info.Mode().Perm() | specialBits)
+	}
+
+	if err := pendingFile.CloseAtomicallyReplace(); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			pendingFile.Cleanup()
+			return writeFileInPlace(target, contents)
+		}
+		return err
+	}
+	return nil
+}
+
+// canChown returns true if a new file can be given to the user uid. Only
+// root can give files away.
+func canChown(uid int) bool {
+	euid := os.Geteuid()
+	return euid == 0 || euid == uid
+}
+
+// resolveSymlinks returns the path of the file which a symlink points to. It
+// also works for dangling symlinks, in which case the target will be created.
+func resolveSymlinks(filename string) (string, error) {
+	target := filename
+	for range 255 {
+		info, err := os.Lstat(target)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return target, nil
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return target, nil
+		}
+
+		link, err := os.Readlink(target)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(target), link)
+		}
+		target = link
+	}
+	return "", &fs.PathError{Op: "resolve", Path: filename, Err: syscall.ELOOP}
+}
+
+func writeFileInPlace(filename string, contents []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(contents); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
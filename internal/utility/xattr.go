@@ -0,0 +1,40 @@
+//go:build linux || darwin
+
+package utility
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of the file at sourcePath onto
+// an open file. Errors are ignored, as not every filesystem supports them.
+func copyXattrs(sourcePath string, dest *os.File) {
+	size, err := unix.Listxattr(sourcePath, nil)
+	if err != nil || size <= 0 {
+		return
+	}
+	names := make([]byte, size)
+	size, err = unix.Listxattr(sourcePath, names)
+	if err != nil {
+		return
+	}
+
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		valueSize, err := unix.Getxattr(sourcePath, string(name), nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Getxattr(sourcePath, string(name), value)
+		if err != nil {
+			continue
+		}
+		unix.Fsetxattr(int(dest.Fd()), string(name), value[:valueSize], 0)
+	}
+}
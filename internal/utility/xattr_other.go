@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package utility
+
+import "os"
+
+func copyXattrs(sourcePath string, dest *os.File) {
+}